@@ -0,0 +1,11 @@
+// Package catalogs embeds the raw commands catalogues: raw-commands.yaml for the latest OPNsense
+// and raw-commands-<version>.yaml for specific releases, e.g. raw-commands-24.7.yaml. Only raw-commands.yaml is
+// shipped for now, catalogues of other releases are read with --commands-dir
+package catalogs
+
+import "embed"
+
+// FS holds the catalogue files
+//
+//go:embed raw-commands*.yaml
+var FS embed.FS
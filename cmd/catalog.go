@@ -0,0 +1,24 @@
+/*
+Copyright © 2023 Dataflows
+*/
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var cmdCatalog = &cobra.Command{
+	Use:     "catalog",
+	Short:   "Generate and inspect raw commands catalogues",
+	Long:    ``,
+	Aliases: []string{"c"},
+	Run:     RunCatalog,
+}
+
+func init() {
+	rootCmd.AddCommand(cmdCatalog)
+}
+
+func RunCatalog(cmd *cobra.Command, _ []string) {
+	_ = cmd.Help()
+}
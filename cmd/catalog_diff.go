@@ -0,0 +1,339 @@
+/*
+Copyright © 2023 Dataflows
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/goccy/go-yaml"
+	"github.com/spf13/cobra"
+	"github.com/thedataflows/go-commons/pkg/log"
+	"github.com/thedataflows/opnsense-cli/pkg/catalog"
+)
+
+const (
+	keyCmdCatalogDiffOutput = "output"
+
+	catalogAdded   = "added"
+	catalogRemoved = "removed"
+	catalogChanged = "changed"
+)
+
+var (
+	cmdCatalogDiff = &cobra.Command{
+		Use:   "diff <old> <new>",
+		Short: "Compare two raw commands catalogues",
+		Long: `Compare two raw commands catalogues, given as files or as versions of the loaded catalogues, e.g. 'catalog diff 24.1 24.7'.
+Reports the added, removed and changed commands: method, parameters and request body fields.
+With --macro-file, the macro steps broken by the new catalogue are reported too and the command fails when any is found:
+steps calling removed commands, with a method no longer accepted or arguments not matching the new parameters.
+Other changes, like request body fields, do not break the steps, which send no body.`,
+		Args: cobra.ExactArgs(2),
+		Run:  RunCatalogDiff,
+	}
+
+	catalogDiffOutput     string
+	catalogDiffMacroFiles []string
+)
+
+// catalogChange is a difference of a raw command between two catalogues
+type catalogChange struct {
+	Command string   `json:"command" yaml:"command"`
+	Change  string   `json:"change" yaml:"change"`
+	Details []string `json:"details,omitempty" yaml:"details,omitempty"`
+}
+
+// catalogMacroImpact is a macro step broken by the new catalogue
+type catalogMacroImpact struct {
+	Macro   string   `json:"macro" yaml:"macro"`
+	Step    string   `json:"step" yaml:"step"`
+	Command string   `json:"command" yaml:"command"`
+	Change  string   `json:"change" yaml:"change"`
+	Details []string `json:"details,omitempty" yaml:"details,omitempty"`
+}
+
+// catalogDiff is the result of comparing two catalogues
+type catalogDiff struct {
+	Old     string               `json:"old" yaml:"old"`
+	New     string               `json:"new" yaml:"new"`
+	Changes []catalogChange      `json:"changes" yaml:"changes"`
+	Macros  []catalogMacroImpact `json:"macros,omitempty" yaml:"macros,omitempty"`
+}
+
+func init() {
+	cmdCatalog.AddCommand(cmdCatalogDiff)
+
+	cmdCatalogDiff.Flags().StringVarP(&catalogDiffOutput, keyCmdCatalogDiffOutput, "o", "table", "Output format, one of: 'table, json, yaml'")
+	cmdCatalogDiff.Flags().StringSliceVar(
+		&catalogDiffMacroFiles,
+		keyCmdMacroFile,
+		nil,
+		"Macro files, directories or globs whose steps are checked against the new catalogue, along with the built-in macros",
+	)
+}
+
+func RunCatalogDiff(_ *cobra.Command, args []string) {
+	oldCatalog, err := findRawCatalog(args[0])
+	if err != nil {
+		log.Fatal(err)
+	}
+	newCatalog, err := findRawCatalog(args[1])
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	diff := &catalogDiff{
+		Old:     oldCatalog.Source,
+		New:     newCatalog.Source,
+		Changes: diffRawCatalogs(oldCatalog, newCatalog),
+	}
+	if len(catalogDiffMacroFiles) > 0 {
+		diff.Macros = macroCatalogImpacts(loadMacros(catalogDiffMacroFiles), oldCatalog, newCatalog)
+	}
+
+	switch catalogDiffOutput {
+	case "json":
+		out, err := json.MarshalIndent(diff, "", "    ")
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println(string(out))
+	case "yaml":
+		out, err := yaml.MarshalWithOptions(diff, yaml.Indent(2))
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Print(string(out))
+	case "table":
+		printCatalogDiff(diff)
+	default:
+		log.Fatalf("Unknown output format '%s'", catalogDiffOutput)
+	}
+
+	if len(diff.Macros) > 0 {
+		log.Errorf("%d macro step(s) are broken by the new catalogue", len(diff.Macros))
+		os.Exit(1)
+	}
+}
+
+// findRawCatalog reads a catalogue file or returns the loaded catalogue of the given version, 'latest' for the unversioned one
+func findRawCatalog(name string) (*rawCatalog, error) {
+	if _, err := os.Stat(name); err == nil {
+		contents, err := os.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		return parseRawCatalog(name, contents)
+	}
+	versions := make([]string, 0, len(rawCatalogs))
+	for _, c := range rawCatalogs {
+		if c.Name() == name {
+			return c, nil
+		}
+		versions = append(versions, c.Name())
+	}
+	return nil, fmt.Errorf("'%s' is neither a file nor a loaded catalogue version, one of: %s", name, strings.Join(versions, ", "))
+}
+
+// diffRawCatalogs returns the commands added, removed and changed from oldCatalog to newCatalog, sorted by name
+func diffRawCatalogs(oldCatalog *rawCatalog, newCatalog *rawCatalog) []catalogChange {
+	names := map[string]bool{}
+	for _, c := range []*rawCatalog{oldCatalog, newCatalog} {
+		for i := range c.Commands {
+			names[c.Commands[i].Name()] = true
+		}
+	}
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	changes := []catalogChange{}
+	for _, name := range sorted {
+		oldCmd, newCmd := oldCatalog.Command(name), newCatalog.Command(name)
+		switch {
+		case oldCmd == nil:
+			changes = append(changes, catalogChange{Command: name, Change: catalogAdded})
+		case newCmd == nil:
+			changes = append(changes, catalogChange{Command: name, Change: catalogRemoved})
+		default:
+			if details := diffRawCommands(oldCmd, newCmd); len(details) > 0 {
+				changes = append(changes, catalogChange{Command: name, Change: catalogChanged, Details: details})
+			}
+		}
+	}
+	return changes
+}
+
+// diffRawCommands describes the changes of a command definition
+func diffRawCommands(oldCmd *catalog.Command, newCmd *catalog.Command) []string {
+	var details []string
+	if !strings.EqualFold(oldCmd.Method, newCmd.Method) {
+		details = append(details, fmt.Sprintf("method %s -> %s", oldCmd.Method, newCmd.Method))
+	}
+	if strings.Join(oldCmd.Parameters, ",") != strings.Join(newCmd.Parameters, ",") {
+		details = append(details, fmt.Sprintf("parameters [%s] -> [%s]", strings.Join(oldCmd.Parameters, ", "), strings.Join(newCmd.Parameters, ", ")))
+	}
+	if oldQuery, newQuery := inputNames(oldCmd.Query), inputNames(newCmd.Query); oldQuery != newQuery {
+		details = append(details, fmt.Sprintf("query [%s] -> [%s]", oldQuery, newQuery))
+	}
+	if oldPost, newPost := inputNames(oldCmd.Post), inputNames(newCmd.Post); oldPost != newPost {
+		details = append(details, fmt.Sprintf("body fields [%s] -> [%s]", oldPost, newPost))
+	}
+	switch {
+	case oldCmd.Body == nil && newCmd.Body == nil:
+	case oldCmd.Body == nil:
+		details = append(details, fmt.Sprintf("body '%s' added", newCmd.Body.Key))
+	case newCmd.Body == nil:
+		details = append(details, fmt.Sprintf("body '%s' removed", oldCmd.Body.Key))
+	case oldCmd.Body.Key != newCmd.Body.Key:
+		details = append(details, fmt.Sprintf("body key %s -> %s", oldCmd.Body.Key, newCmd.Body.Key))
+	default:
+		details = append(details, diffBodyFields(oldCmd.Body.Key, oldCmd.Body.Fields, newCmd.Body.Fields)...)
+	}
+	return details
+}
+
+// diffBodyFields describes the added, removed and changed fields of a request body
+func diffBodyFields(path string, oldFields []catalog.Field, newFields []catalog.Field) []string {
+	var details []string
+	byName := make(map[string]*catalog.Field, len(newFields))
+	for i := range newFields {
+		byName[newFields[i].Name] = &newFields[i]
+	}
+	for i := range oldFields {
+		oldField := &oldFields[i]
+		fieldPath := path + "." + oldField.Name
+		newField, ok := byName[oldField.Name]
+		if !ok {
+			details = append(details, fmt.Sprintf("field %s removed", fieldPath))
+			continue
+		}
+		delete(byName, oldField.Name)
+		if oldField.Type != newField.Type {
+			details = append(details, fmt.Sprintf("field %s type %s -> %s", fieldPath, oldField.Type, newField.Type))
+		}
+		if !oldField.Required && newField.Required && len(newField.Default) == 0 {
+			details = append(details, fmt.Sprintf("field %s is now required", fieldPath))
+		}
+		for _, option := range oldField.Options {
+			if !contains(newField.Options, option) && len(newField.Options) > 0 {
+				details = append(details, fmt.Sprintf("field %s option '%s' removed", fieldPath, option))
+			}
+		}
+		details = append(details, diffBodyFields(fieldPath, oldField.Fields, newField.Fields)...)
+	}
+	for i := range newFields {
+		if _, added := byName[newFields[i].Name]; added {
+			required := ""
+			if newFields[i].Required && len(newFields[i].Default) == 0 {
+				required = ", required"
+			}
+			details = append(details, fmt.Sprintf("field %s.%s added%s", path, newFields[i].Name, required))
+		}
+	}
+	return details
+}
+
+// macroCatalogImpacts returns the macro steps broken by the new catalogue: calling removed commands, or commands
+// changed in a way the steps no longer fit. Other changes, like new optional parameters, are not reported
+func macroCatalogImpacts(macroList *[]Macro, oldCatalog *rawCatalog, newCatalog *rawCatalog) []catalogMacroImpact {
+	impacts := []catalogMacroImpact{}
+	var walk func(macro *Macro, steps []MacroStep, prefix string)
+	walk = func(macro *Macro, steps []MacroStep, prefix string) {
+		for i := range steps {
+			step := &steps[i]
+			label := fmt.Sprintf("%s%d", prefix, i+1)
+			walk(macro, step.Parallel, label+".")
+			if len(step.Command) == 0 {
+				continue
+			}
+			oldCmd, newCmd := oldCatalog.Command(step.Command), newCatalog.Command(step.Command)
+			impact := catalogMacroImpact{Macro: macro.FullName(), Step: label, Command: step.Command}
+			switch {
+			case newCmd == nil && oldCmd == nil:
+				// not a change of the catalogues, reported by 'macro validate'
+				continue
+			case newCmd == nil:
+				impact.Change = catalogRemoved
+			default:
+				impact.Change = catalogChanged
+				impact.Details = breakingStepChanges(step, oldCmd, newCmd)
+				if len(impact.Details) == 0 {
+					continue
+				}
+			}
+			impacts = append(impacts, impact)
+		}
+	}
+	for i := range *macroList {
+		macro := &(*macroList)[i]
+		walk(macro, macro.Commands, "")
+	}
+	return impacts
+}
+
+// breakingStepChanges describes the changes from oldCmd to newCmd breaking a macro step: the method the step calls
+// with no longer accepted or its arguments not matching the new parameters. Macro steps send no request body, so
+// body changes are only listed in the diff. oldCmd is nil when the command is new
+func breakingStepChanges(step *MacroStep, oldCmd *catalog.Command, newCmd *catalog.Command) []string {
+	var details []string
+	method := strings.ToUpper(step.Method)
+	if len(method) == 0 && oldCmd != nil {
+		method = oldCmd.Method
+	}
+	accepted := []string{newCmd.Method}
+	if len(newCmd.Methods) > 0 {
+		accepted = accepted[:0]
+		for _, m := range newCmd.Methods {
+			accepted = append(accepted, m.Method)
+		}
+	}
+	if len(method) > 0 && !contains(accepted, method) {
+		details = append(details, fmt.Sprintf("method %s -> %s", method, strings.Join(accepted, " or ")))
+	}
+	// steps without args are given the arguments of the command line, and parameters with a default, like
+	// '$uuid=null', can be left out
+	required := 0
+	for _, p := range newCmd.Parameters {
+		if !strings.Contains(p, "=") {
+			required++
+		}
+	}
+	if len(step.Args) > 0 && len(step.Args) < required || len(step.Args) > len(newCmd.Parameters) {
+		details = append(details, fmt.Sprintf("parameters [%s], the step has %d argument(s)", strings.Join(newCmd.Parameters, ", "), len(step.Args)))
+	}
+	return details
+}
+
+// printCatalogDiff prints the changes and macro impacts as tables
+func printCatalogDiff(diff *catalogDiff) {
+	fmt.Printf("Comparing %s to %s\n\n", diff.Old, diff.New)
+	counts := map[string]int{}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CHANGE\tCOMMAND\tDETAILS")
+	for _, c := range diff.Changes {
+		counts[c.Change]++
+		fmt.Fprintf(w, "%s\t%s\t%s\n", c.Change, c.Command, strings.Join(c.Details, "; "))
+	}
+	_ = w.Flush()
+	fmt.Printf("\n%d added, %d removed, %d changed\n", counts[catalogAdded], counts[catalogRemoved], counts[catalogChanged])
+
+	if len(diff.Macros) == 0 {
+		return
+	}
+	fmt.Println()
+	w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "MACRO\tSTEP\tCOMMAND\tCHANGE\tDETAILS")
+	for _, m := range diff.Macros {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", m.Macro, m.Step, m.Command, m.Change, strings.Join(m.Details, "; "))
+	}
+	_ = w.Flush()
+}
@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/thedataflows/opnsense-cli/pkg/catalog"
+)
+
+func TestBreakingStepChanges(t *testing.T) {
+	body := func(fields ...catalog.Field) *catalog.Body {
+		return &catalog.Body{Key: "alias", Model: "OPNsense/Firewall/Alias", Fields: fields}
+	}
+	tests := []struct {
+		name   string
+		step   MacroStep
+		oldCmd *catalog.Command
+		newCmd *catalog.Command
+		want   []string
+	}{
+		{
+			name:   "unchanged",
+			oldCmd: &catalog.Command{Method: "GET", Parameters: []string{"$uuid"}},
+			newCmd: &catalog.Command{Method: "GET", Parameters: []string{"$uuid"}},
+		},
+		{
+			name:   "additive",
+			step:   MacroStep{Args: []string{"abc"}},
+			oldCmd: &catalog.Command{Method: "POST", Parameters: []string{"$uuid"}, Body: body(catalog.Field{Name: "name", Type: "TextField"})},
+			newCmd: &catalog.Command{
+				Method:     "POST",
+				Parameters: []string{"$uuid", "$name=null"},
+				Query:      []catalog.Input{{Name: "searchPhrase"}},
+				Body:       body(catalog.Field{Name: "name", Type: "TextField"}, catalog.Field{Name: "color", Type: "TextField"}),
+			},
+		},
+		{
+			name:   "body added",
+			oldCmd: &catalog.Command{Method: "POST"},
+			newCmd: &catalog.Command{Method: "POST", Body: body(catalog.Field{Name: "name", Type: "TextField", Required: true})},
+		},
+		{
+			name:   "method",
+			oldCmd: &catalog.Command{Method: "GET"},
+			newCmd: &catalog.Command{Method: "POST"},
+			want:   []string{"method GET -> POST"},
+		},
+		{
+			name:   "method of the step still accepted",
+			step:   MacroStep{Method: "post"},
+			oldCmd: &catalog.Command{Method: "GET"},
+			newCmd: &catalog.Command{Method: "GET", Methods: []catalog.MethodEvidence{{Method: "GET"}, {Method: "POST"}}},
+		},
+		{
+			name:   "arguments",
+			step:   MacroStep{Args: []string{"abc"}},
+			oldCmd: &catalog.Command{Method: "GET", Parameters: []string{"$uuid"}},
+			newCmd: &catalog.Command{Method: "GET", Parameters: []string{"$uuid", "$name"}},
+			want:   []string{"parameters [$uuid, $name], the step has 1 argument(s)"},
+		},
+		{
+			name:   "arguments of the command line",
+			oldCmd: &catalog.Command{Method: "GET", Parameters: []string{"$uuid"}},
+			newCmd: &catalog.Command{Method: "GET", Parameters: []string{"$uuid", "$name"}},
+		},
+		// macro steps send no body
+		{
+			name: "fields",
+			oldCmd: &catalog.Command{Method: "POST", Body: body(
+				catalog.Field{Name: "name", Type: "TextField"},
+				catalog.Field{Name: "type", Type: "OptionField"},
+			)},
+			newCmd: &catalog.Command{Method: "POST", Body: body(
+				catalog.Field{Name: "name", Type: "TextField", Required: true},
+				catalog.Field{Name: "content", Type: "TextField", Required: true, Default: "any"},
+				catalog.Field{Name: "proto", Type: "OptionField", Required: true},
+			)},
+		},
+		{
+			name:   "body removed",
+			oldCmd: &catalog.Command{Method: "POST", Body: body()},
+			newCmd: &catalog.Command{Method: "POST"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := breakingStepChanges(&tt.step, tt.oldCmd, tt.newCmd); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("breakingStepChanges() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
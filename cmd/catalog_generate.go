@@ -0,0 +1,62 @@
+/*
+Copyright © 2023 Dataflows
+*/
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/thedataflows/go-commons/pkg/log"
+	"github.com/thedataflows/opnsense-cli/pkg/generator"
+)
+
+var (
+	cmdCatalogGenerate = &cobra.Command{
+		Use:   "generate",
+		Short: "Generate the raw commands catalogue from the OPNsense sources",
+		Long: `Collect the API endpoints from the controllers and models of the OPNsense core and plugins sources.
+By default the repositories are cloned from GitHub, use --source to read local directories or release tarballs instead.`,
+		Example: `  opnsense-cli catalog generate --ref 24.7 --output raw-commands-24.7.yaml
+  opnsense-cli catalog generate --offline --source core-24.7.tar.gz --source plugins-24.7.tar.gz
+  opnsense-cli catalog generate --source ./core --format openapi --output openapi.json
+  opnsense-cli catalog generate --source ./core --format man --output man/man7`,
+		Args: cobra.NoArgs,
+		Run:  RunCatalogGenerate,
+	}
+
+	catalogGenerateOptions = generator.Options{}
+)
+
+func init() {
+	cmdCatalog.AddCommand(cmdCatalogGenerate)
+
+	flags := cmdCatalogGenerate.Flags()
+	flags.StringSliceVar(&catalogGenerateOptions.Sources, "source", nil, "Source directories or tarballs, e.g. core-24.7.tar.gz, read instead of cloning the repositories. Can be specified multiple times")
+	flags.StringSliceVar(&catalogGenerateOptions.Repos, "repo", generator.DefaultRepos, "OPNsense repositories to clone")
+	flags.StringVar(&catalogGenerateOptions.Ref, "ref", "", "Branch, tag or commit of the cloned repositories, e.g. 24.7, instead of their default branch")
+	flags.StringVar(&catalogGenerateOptions.CloneDir, "clone-dir", "", "Directory the repositories are cloned to, the temporary directory by default")
+	flags.BoolVar(&catalogGenerateOptions.Offline, "offline", false, "Only read --source directories or tarballs, never clone the repositories")
+	flags.StringVar(
+		&catalogGenerateOptions.Format,
+		"format",
+		generator.FormatCatalog,
+		fmt.Sprintf(
+			"Output format, one of: '%s'. '%s' writes an OpenAPI 3.1 document, as JSON when the output file ends with .json, '%s' a go SDK in the output directory, '%s' and '%s' the API reference of every module in the output directory",
+			strings.Join(generator.Formats, ", "),
+			generator.FormatOpenAPI,
+			generator.FormatSDK,
+			generator.FormatMarkdown,
+			generator.FormatMan,
+		),
+	)
+	flags.StringVar(&catalogGenerateOptions.Output, "output", "raw-commands.yaml", "Output file, or directory of the SDK and API reference")
+	flags.StringVar(&catalogGenerateOptions.Template, "template", "", "Template of the catalogue, by default the output file name with the .gotmpl extension when it exists, else the embedded one")
+}
+
+func RunCatalogGenerate(_ *cobra.Command, _ []string) {
+	if err := generator.Generate(&catalogGenerateOptions); err != nil {
+		log.Fatal(err)
+	}
+}
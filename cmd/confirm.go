@@ -0,0 +1,43 @@
+/*
+Copyright © 2023 Dataflows
+*/
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/mattn/go-isatty"
+	"github.com/thedataflows/go-commons/pkg/config"
+	"github.com/thedataflows/go-commons/pkg/log"
+)
+
+// isTerminal returns true when stdin is attached to a terminal
+func isTerminal() bool {
+	return isatty.IsTerminal(os.Stdin.Fd()) || isatty.IsCygwinTerminal(os.Stdin.Fd())
+}
+
+// confirmAction prints the summary and asks the user to confirm it, unless --yes was given.
+// Without a terminal to ask on, the action is refused
+func confirmAction(summary string) error {
+	if config.ViperGetBool(rootCmd, keyCommonYes) {
+		return nil
+	}
+	if !isTerminal() {
+		log.Warn(summary)
+		return fmt.Errorf("confirmation required but stdin is not a terminal, use --%s to proceed", keyCommonYes)
+	}
+
+	fmt.Fprintf(os.Stderr, "%s\nContinue? [y/N]: ", summary)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("failed to read confirmation: %w", err)
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return nil
+	}
+	return fmt.Errorf("not confirmed")
+}
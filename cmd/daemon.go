@@ -0,0 +1,94 @@
+/*
+Copyright © 2023 Dataflows
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"github.com/robfig/cron/v3"
+	"github.com/spf13/cobra"
+	"github.com/thedataflows/go-commons/pkg/config"
+	"github.com/thedataflows/go-commons/pkg/log"
+)
+
+var (
+	cmdDaemon = &cobra.Command{
+		Use:   "daemon",
+		Short: "Run the macros having a schedule, until interrupted",
+		Long: `Run the macros having a schedule, until interrupted.
+
+Macros run one at a time and their step results are logged instead of printed, use --log-format json for structured logs.
+Steps requiring confirmation are refused unless --yes is given.`,
+		Args: cobra.NoArgs,
+		Run:  RunDaemon,
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(cmdDaemon)
+
+	cmdDaemon.Flags().StringSliceVar(
+		&macroFiles,
+		keyCmdMacroFile,
+		[]string{defaultMacroFile},
+		fmt.Sprintf("Macro files, directories or globs, YAML format. Can be specified multiple times. '%s' is always searched", macroSearchPathHelp),
+	)
+	cmdDaemon.Flags().StringToStringVar(&macroRunVars, keyCmdMacroRunVar, nil, "Set macro variables, e.g. --var uuid=abc. Can be specified multiple times")
+
+	config.ViperBindPFlagSet(cmdDaemon, cmdDaemon.Flags())
+}
+
+func RunDaemon(cmd *cobra.Command, _ []string) {
+	macroQuiet = true
+	macroList := loadMacros(macroFiles)
+	if err := selectRawCatalog(cmd, true); err != nil {
+		log.Fatal(err)
+	}
+
+	scheduler := cron.New()
+	// running serializes the runs, as they share the report and may change the same configuration
+	var running sync.Mutex
+	for i := range *macroList {
+		macro := &(*macroList)[i]
+		if len(macro.Schedule) == 0 {
+			continue
+		}
+		if findMacro(macroList, macro.Name) != macro {
+			log.Infof("Macro '%s' is overridden by another file, not scheduling it", macro.FullName())
+			continue
+		}
+		problems := validateMacroCalls(macroList, macro)
+		if len(problems) > 0 {
+			for _, p := range problems {
+				log.Error(p)
+			}
+			log.Fatalf("Refusing to schedule invalid macro '%s'", macro.FullName())
+		}
+		_, err := scheduler.AddFunc(macro.Schedule, func() {
+			running.Lock()
+			defer running.Unlock()
+			_ = runMacroJob(macroList, []*Macro{macro}, macroRunVars, nil)
+			macroRunReport.log(true)
+		})
+		if err != nil {
+			log.Fatalf("Macro '%s': %v", macro.FullName(), err)
+		}
+		log.Infof("Scheduled macro '%s': %s", macro.FullName(), macro.Schedule)
+	}
+	if len(scheduler.Entries()) == 0 {
+		log.Fatal("No macro with a schedule found")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	scheduler.Start()
+	<-ctx.Done()
+	log.Info("Stopping, waiting for the running macro to finish")
+	<-scheduler.Stop().Done()
+}
@@ -0,0 +1,24 @@
+/*
+Copyright © 2023 Dataflows
+*/
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var cmdFirewall = &cobra.Command{
+	Use:     "firewall",
+	Short:   "Manage the firewall",
+	Long:    ``,
+	Aliases: []string{"fw"},
+	Run:     RunFirewall,
+}
+
+func init() {
+	rootCmd.AddCommand(cmdFirewall)
+}
+
+func RunFirewall(cmd *cobra.Command, _ []string) {
+	_ = cmd.Help()
+}
@@ -0,0 +1,23 @@
+/*
+Copyright © 2023 Dataflows
+*/
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var cmdFirewallRules = &cobra.Command{
+	Use:   "rules",
+	Short: "Manage the firewall filter rules",
+	Long:  ``,
+	Run:   RunFirewallRules,
+}
+
+func init() {
+	cmdFirewall.AddCommand(cmdFirewallRules)
+}
+
+func RunFirewallRules(cmd *cobra.Command, _ []string) {
+	_ = cmd.Help()
+}
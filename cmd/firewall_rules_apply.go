@@ -0,0 +1,208 @@
+/*
+Copyright © 2023 Dataflows
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/thedataflows/go-commons/pkg/log"
+	"github.com/thedataflows/opnsense-cli/pkg/api"
+)
+
+const (
+	keyCmdFirewallRulesApplyCheckCommand = "check-command"
+	keyCmdFirewallRulesApplyCheckTimeout = "check-timeout"
+
+	firewallSavepointCommand      = "firewall/filter/savepoint"
+	firewallApplyCommand          = "firewall/filter/apply"
+	firewallCancelRollbackCommand = "firewall/filter/cancelRollback"
+	firewallRevertCommand         = "firewall/filter/revert"
+
+	// firewallRollbackDelay is the delay after which OPNsense reverts to the savepoint given to apply, unless cancelled
+	firewallRollbackDelay = 60 * time.Second
+	// firewallCheckInterval is the delay between the connectivity checks
+	firewallCheckInterval = 2 * time.Second
+	// firewallCheckRequestTimeout bounds every connectivity check, as dropped packets make requests hang
+	firewallCheckRequestTimeout = 5 * time.Second
+)
+
+var (
+	cmdFirewallRulesApply = &cobra.Command{
+		Use:   "apply",
+		Short: "Apply the pending filter rule changes, reverted unless the API is still reachable",
+		Long: fmt.Sprintf(`Apply the pending firewall filter rule changes with a safety net:
+1. take a savepoint of the current rules (%s)
+2. apply the changes, OPNsense reverting to the savepoint after %s (%s)
+3. call --check-command over a new connection until it succeeds or --check-timeout passes
+4. when the check passed before the revert, cancel the revert (%s)
+
+When the check fails, the automatic revert is left to happen and reported. The command then fails.`,
+			firewallSavepointCommand,
+			firewallRollbackDelay,
+			firewallApplyCommand,
+			firewallCancelRollbackCommand,
+		),
+		Example: `  opnsense-cli raw firewall/filter/setRule $uuid --data '{"rule":{"enabled":"0"}}'
+  opnsense-cli firewall rules apply`,
+		Args: cobra.NoArgs,
+		Run:  RunFirewallRulesApply,
+	}
+
+	firewallRulesApplyCheckCommand string
+	firewallRulesApplyCheckTimeout time.Duration
+)
+
+func init() {
+	cmdFirewallRules.AddCommand(cmdFirewallRulesApply)
+
+	cmdFirewallRulesApply.Flags().StringVar(&firewallRulesApplyCheckCommand, keyCmdFirewallRulesApplyCheckCommand, "firewall/filter/searchRule", "Raw command called to check the API is reachable after applying the rules")
+	cmdFirewallRulesApply.Flags().DurationVar(
+		&firewallRulesApplyCheckTimeout,
+		keyCmdFirewallRulesApplyCheckTimeout,
+		30*time.Second,
+		fmt.Sprintf("Time for the API to be reachable after applying the rules, less than the %s OPNsense waits before reverting them", firewallRollbackDelay),
+	)
+}
+
+func RunFirewallRulesApply(cmd *cobra.Command, _ []string) {
+	if firewallRulesApplyCheckTimeout <= 0 || firewallRulesApplyCheckTimeout >= firewallRollbackDelay {
+		log.Fatalf("--%s must be positive and less than %s", keyCmdFirewallRulesApplyCheckTimeout, firewallRollbackDelay)
+	}
+	if err := selectRawCatalog(cmd, false); err != nil {
+		log.Fatal(err)
+	}
+	checkCmd := findRawCommand(firewallRulesApplyCheckCommand)
+	if checkCmd == nil {
+		log.Fatal(rawCommandNotFound(firewallRulesApplyCheckCommand))
+	}
+	if parameters := rawCommandParameters(checkCmd); len(parameters) > 0 {
+		log.Fatalf("--%s must not take arguments, '%s' expects %v", keyCmdFirewallRulesApplyCheckCommand, firewallRulesApplyCheckCommand, parameters)
+	}
+	if err := confirmAction(fmt.Sprintf("Apply the pending firewall filter rule changes, reverted after %s unless the API is still reachable?", firewallRollbackDelay)); err != nil {
+		log.Fatal(err)
+	}
+
+	client, err := opnSenseClient(cmd)
+	if err != nil {
+		log.Fatal(err)
+	}
+	ctx := context.Background()
+
+	log.Infof("%s %s", http.MethodPost, client.CommandURL(firewallSavepointCommand, nil))
+	resp, err := client.Call(ctx, http.MethodPost, firewallSavepointCommand, nil, nil)
+	if err != nil {
+		log.Fatalf("Failed to take a savepoint, nothing applied: %s", err)
+	}
+	var savepoint struct {
+		Revision string `json:"revision"`
+	}
+	if err := resp.Decode(&savepoint); err != nil || len(savepoint.Revision) == 0 {
+		log.Fatalf("Failed to take a savepoint, nothing applied: no revision in the response %s", resp.Body)
+	}
+	log.Infof("Savepoint %s", savepoint.Revision)
+
+	log.Infof("%s %s", http.MethodPost, client.CommandURL(firewallApplyCommand, []string{savepoint.Revision}))
+	applied := time.Now()
+	revertAt := applied.Add(firewallRollbackDelay)
+	// the applied rules may drop the packets of the apply request itself, which would then hang past the revert
+	applyCtx, cancelApply := context.WithTimeout(ctx, firewallCheckRequestTimeout)
+	resp, err = client.Call(applyCtx, http.MethodPost, firewallApplyCommand, []string{savepoint.Revision}, nil)
+	cancelApply()
+	var statusErr *api.StatusError
+	switch {
+	case errors.As(err, &statusErr):
+		log.Fatalf("Failed to apply the rules: %s", err)
+	case err != nil:
+		log.Warnf("No response to apply, checking whether the API is reachable: %s", err)
+	default:
+		log.Infof("Applied: %s", strings.TrimSpace(string(resp.Body)))
+	}
+
+	method := checkCmd.Annotations["method"]
+	if err := checkFirewallConnectivity(ctx, client, method, applied.Add(firewallRulesApplyCheckTimeout)); err != nil {
+		log.Errorf("The API is unreachable after applying the rules: %s", err)
+		reportFirewallRollback(ctx, client, method, savepoint.Revision, revertAt)
+		os.Exit(1)
+	}
+	// a check passing after the revert tells nothing about the applied rules
+	if time.Now().After(revertAt) {
+		log.Fatalf("The API was reachable only after OPNsense reverted to the savepoint %s at %s, fix the pending changes and apply them again", savepoint.Revision, revertAt.Format(time.TimeOnly))
+	}
+
+	log.Infof("%s %s", http.MethodPost, client.CommandURL(firewallCancelRollbackCommand, []string{savepoint.Revision}))
+	// cancelling after the revert would not bring the applied rules back
+	cancelCtx, cancelCancel := context.WithDeadline(ctx, revertAt)
+	_, err = client.Call(cancelCtx, http.MethodPost, firewallCancelRollbackCommand, []string{savepoint.Revision}, nil)
+	cancelCancel()
+	if err != nil {
+		log.Fatalf(
+			"The API is reachable but cancelling the revert failed, OPNsense reverts to the savepoint %s at %s: %s",
+			savepoint.Revision,
+			revertAt.Format(time.TimeOnly),
+			err,
+		)
+	}
+	log.Infof("Rules applied and the API is reachable, revert to the savepoint %s cancelled", savepoint.Revision)
+}
+
+// checkFirewallConnectivity calls the check command until it succeeds or the deadline passes, returning the last error
+func checkFirewallConnectivity(ctx context.Context, client *api.Client, method string, deadline time.Time) error {
+	checkClient := newConnectionClient(client)
+	url := client.CommandURL(firewallRulesApplyCheckCommand, nil)
+	for {
+		log.Infof("%s %s", method, url)
+		requestCtx, cancel := context.WithTimeout(ctx, firewallCheckRequestTimeout)
+		resp, err := checkClient.Do(requestCtx, method, url, nil)
+		cancel()
+		if err == nil && resp.StatusCode >= http.StatusBadRequest {
+			err = &api.StatusError{Response: resp}
+		}
+		if err == nil {
+			return nil
+		}
+		if time.Now().Add(firewallCheckInterval).After(deadline) {
+			return err
+		}
+		log.Warnf("API not reachable yet: %s", err)
+		time.Sleep(firewallCheckInterval)
+	}
+}
+
+// newConnectionClient returns a copy of client opening a new connection for every request, so that requests are
+// filtered by the applied rules instead of reusing a connection established before
+func newConnectionClient(client *api.Client) *api.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if client.HTTPClient != nil {
+		if t, ok := client.HTTPClient.Transport.(*http.Transport); ok {
+			transport = t.Clone()
+		}
+	}
+	transport.DisableKeepAlives = true
+	c := *client
+	c.HTTPClient = &http.Client{Transport: transport}
+	return &c
+}
+
+// reportFirewallRollback waits for OPNsense to revert to the savepoint, then reports whether the API is reachable again
+func reportFirewallRollback(ctx context.Context, client *api.Client, method string, revision string, revertAt time.Time) {
+	log.Warnf("Not cancelling the revert, OPNsense reverts to the savepoint %s at %s", revision, revertAt.Format(time.TimeOnly))
+	time.Sleep(time.Until(revertAt))
+
+	if err := checkFirewallConnectivity(ctx, client, method, time.Now().Add(firewallRulesApplyCheckTimeout)); err != nil {
+		log.Errorf(
+			"The API is still unreachable after the revert: %s. Revert from the console or web interface, or with: opnsense-cli firewall rules revert %s",
+			err,
+			revision,
+		)
+		return
+	}
+	log.Errorf("The rules were reverted to the savepoint %s and the API is reachable again, fix the pending changes and apply them again", revision)
+}
@@ -0,0 +1,46 @@
+/*
+Copyright © 2023 Dataflows
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/thedataflows/go-commons/pkg/log"
+)
+
+var cmdFirewallRulesRevert = &cobra.Command{
+	Use:   "revert <revision>",
+	Short: "Revert the firewall filter rules to a savepoint",
+	Long: fmt.Sprintf(`Revert the firewall filter rules to a savepoint revision (%s), as taken by 'opnsense-cli firewall rules apply'.
+The revert is sent straight to the API, also when the raw commands catalogue does not list it.`,
+		firewallRevertCommand,
+	),
+	Example: `  opnsense-cli firewall rules revert 1700000000.12`,
+	Args:    cobra.ExactArgs(1),
+	Run:     RunFirewallRulesRevert,
+}
+
+func init() {
+	cmdFirewallRules.AddCommand(cmdFirewallRulesRevert)
+}
+
+func RunFirewallRulesRevert(cmd *cobra.Command, args []string) {
+	if err := confirmAction(fmt.Sprintf("Revert the firewall filter rules to the savepoint %s?", args[0])); err != nil {
+		log.Fatal(err)
+	}
+	client, err := opnSenseClient(cmd)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Infof("%s %s", http.MethodPost, client.CommandURL(firewallRevertCommand, args))
+	resp, err := client.Call(context.Background(), http.MethodPost, firewallRevertCommand, args, nil)
+	if err != nil {
+		log.Fatalf("Failed to revert to the savepoint %s: %s", args[0], err)
+	}
+	log.Infof("Reverted to the savepoint %s: %s", args[0], strings.TrimSpace(string(resp.Body)))
+}
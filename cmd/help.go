@@ -0,0 +1,56 @@
+/*
+Copyright © 2023 Dataflows
+*/
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// cmdHelp replaces the default help command of cobra, to hold the offline API reference as 'help api'
+var cmdHelp = &cobra.Command{
+	Use:   "help [command]",
+	Short: "Help about any command",
+	Long: `Help provides help for any command in the application.
+Simply type opnsense-cli help [path to command] for full details.
+Type opnsense-cli help api [module] for the offline API reference.`,
+	ValidArgsFunction: helpCompletion,
+	Run:               RunHelp,
+}
+
+func init() {
+	rootCmd.SetHelpCommand(cmdHelp)
+}
+
+func RunHelp(c *cobra.Command, args []string) {
+	cmd, _, err := c.Root().Find(args)
+	if cmd == nil || err != nil {
+		c.Printf("Unknown help topic %#q\n", args)
+		cobra.CheckErr(c.Root().Usage())
+		return
+	}
+	cmd.InitDefaultHelpFlag()
+	cmd.InitDefaultVersionFlag()
+	cobra.CheckErr(cmd.Help())
+}
+
+// helpCompletion completes the path to a command, like the default help command
+func helpCompletion(c *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	cmd, _, err := c.Root().Find(args)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	if cmd == nil {
+		cmd = c.Root()
+	}
+	var completions []string
+	for _, subCmd := range cmd.Commands() {
+		if (subCmd.IsAvailableCommand() || subCmd.Name() == "help") && strings.HasPrefix(subCmd.Name(), toComplete) {
+			completions = append(completions, fmt.Sprintf("%s\t%s", subCmd.Name(), subCmd.Short))
+		}
+	}
+	return completions, cobra.ShellCompDirectiveNoFileComp
+}
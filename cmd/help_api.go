@@ -0,0 +1,100 @@
+/*
+Copyright © 2023 Dataflows
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/thedataflows/go-commons/pkg/log"
+	"github.com/thedataflows/opnsense-cli/pkg/apidoc"
+)
+
+const (
+	keyCmdHelpAPIFormat = "format"
+)
+
+var (
+	cmdHelpAPI = &cobra.Command{
+		Use:   "api [module]",
+		Short: "Offline API reference of a module",
+		Long: `Show the controllers, commands, methods, parameters and model fields of an OPNsense module, from the raw commands catalogue.
+Without module, list the modules. The catalogue is selected with --opnsense-version, else the latest one is used.`,
+		Example: `  opnsense-cli help api firewall
+  opnsense-cli help api firewall --format man | man -l -`,
+		Args:              cobra.MaximumNArgs(1),
+		ValidArgsFunction: helpAPICompletion,
+		Run:               RunHelpAPI,
+	}
+
+	helpAPIFormat string
+)
+
+func init() {
+	cmdHelp.AddCommand(cmdHelpAPI)
+
+	cmdHelpAPI.Flags().StringVar(&helpAPIFormat, keyCmdHelpAPIFormat, apidoc.FormatMarkdown, fmt.Sprintf("Output format, one of: '%s'", strings.Join(apidoc.Formats, ", ")))
+}
+
+func RunHelpAPI(cmd *cobra.Command, args []string) {
+	modules, err := helpAPIModules(cmd)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if len(args) == 0 {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "MODULE\tCONTROLLERS\tCOMMANDS\tPLUGINS")
+		for _, m := range modules {
+			commands := 0
+			for _, c := range m.Controllers {
+				commands += len(c.Commands)
+			}
+			fmt.Fprintf(w, "%s\t%d\t%d\t%s\n", m.Name, len(m.Controllers), commands, strings.Join(m.Plugins(), ","))
+		}
+		_ = w.Flush()
+		return
+	}
+
+	for _, m := range modules {
+		if m.Name != args[0] {
+			continue
+		}
+		out, err := m.Render(helpAPIFormat)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Print(string(out))
+		return
+	}
+	log.Fatalf("Unknown module '%s' in the raw commands catalogue %s, list the modules with 'opnsense-cli help api'", args[0], rawCatalogSelected.Source)
+}
+
+// helpAPIModules returns the modules of the raw commands catalogue matching --opnsense-version, without querying OPNsense
+func helpAPIModules(cmd *cobra.Command) ([]*apidoc.Module, error) {
+	if err := selectRawCatalog(cmd, false); err != nil {
+		return nil, err
+	}
+	return apidoc.Modules(rawCatalogSelected.Commands), nil
+}
+
+func helpAPICompletion(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	modules, err := helpAPIModules(cmd)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	completions := []string{}
+	for _, m := range modules {
+		if strings.HasPrefix(m.Name, toComplete) {
+			completions = append(completions, m.Name)
+		}
+	}
+	return completions, cobra.ShellCompDirectiveNoFileComp
+}
@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/thedataflows/opnsense-cli/catalogs"
+	"github.com/thedataflows/opnsense-cli/pkg/apidoc"
+)
+
+func TestHelpAPIRender(t *testing.T) {
+	embedded, err := readRawCatalogs(catalogs.FS, "embedded")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the generator golden catalogue holds what a regenerated catalogue holds: methods, bodies and plugins
+	source := filepath.Join("..", "pkg", "generator", "testdata", "golden", "raw-commands.yaml")
+	contents, err := os.ReadFile(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	generated, err := parseRawCatalog(source, contents)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		catalog *rawCatalog
+		module  string
+		want    []string
+		plugins string
+	}{
+		// the embedded catalogue was generated before bodies and plugins were recorded, regenerate it with
+		// 'make generate' to show the model fields
+		{name: "embedded", catalog: embedded[len(embedded)-1], module: "firewall", want: []string{"## Controller: alias", "### `firewall/alias/addItem`"}},
+		{
+			name:    "generated",
+			catalog: generated,
+			module:  "firewall",
+			want:    []string{"## Controller: alias", "| Field | Type | Required | Default | Options |", "| `alias.enabled` | BooleanField | yes | 1 |  |"},
+		},
+		{name: "plugin", catalog: generated, module: "wol", plugins: "os-wol"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var module *apidoc.Module
+			for _, m := range apidoc.Modules(tt.catalog.Commands) {
+				if m.Name == tt.module {
+					module = m
+				}
+			}
+			if module == nil {
+				t.Fatalf("module %s is missing from %s", tt.module, tt.catalog.Source)
+			}
+			if got := strings.Join(module.Plugins(), ","); got != tt.plugins {
+				t.Errorf("Plugins() = %q, want %q", got, tt.plugins)
+			}
+			out, err := module.Render(apidoc.FormatMarkdown)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(string(out), want) {
+					t.Errorf("Render() does not contain %q", want)
+				}
+			}
+		})
+	}
+}
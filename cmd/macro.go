@@ -4,16 +4,38 @@ Copyright © 2023 Dataflows
 package cmd
 
 import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"net/http"
 	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
 
 	"github.com/goccy/go-yaml"
 	"github.com/spf13/cobra"
 	"github.com/thedataflows/go-commons/pkg/config"
+	"github.com/thedataflows/go-commons/pkg/file"
 	"github.com/thedataflows/go-commons/pkg/log"
 )
 
 const (
-	keyCmdMacroFile = "macro-file"
+	keyCmdMacroFile  = "macro-file"
+	defaultMacroFile = "default-macro.yaml"
+
+	// builtinMacrosName is the namespace and source of the embedded macros
+	builtinMacrosName = "builtin"
+
+	// macroStepsVar is the template variable holding the results of the steps with an id
+	macroStepsVar = "steps"
+	// macroResponseVar and macroStatusCodeVar hold the step response in assertions
+	macroResponseVar   = "response"
+	macroStatusCodeVar = "status_code"
 )
 
 var (
@@ -25,37 +47,459 @@ var (
 		Run:     RunMacro,
 	}
 
-	macroFileName string
+	macroFiles []string
+
+	//go:embed macros/builtin.yaml
+	builtinMacros []byte
 )
 
 type Macro struct {
-	Name     string   `yaml:"name"`
-	Commands []string `yaml:"commands"`
+	Name        string            `yaml:"name" json:"name"`
+	Description string            `yaml:"description,omitempty" json:"description,omitempty"`
+	Tags        []string          `yaml:"tags,omitempty" json:"tags,omitempty"`
+	Params      []MacroParam      `yaml:"params,omitempty" json:"params,omitempty"`
+	Vars        map[string]string `yaml:"vars,omitempty" json:"vars,omitempty"`
+	Commands    []MacroStep       `yaml:"commands" json:"commands"`
+	// Schedule is a cron expression, e.g. '0 3 * * *' or '@every 1h', run by 'opnsense-cli daemon'
+	Schedule string `yaml:"schedule,omitempty" json:"schedule,omitempty"`
+
+	// Namespace is the base name of the file defining the macro
+	Namespace string `yaml:"-" json:"namespace"`
+	// Source is the file defining the macro
+	Source string `yaml:"-" json:"source"`
+}
+
+// MacroParam is a documented macro variable that can be set with --var
+type MacroParam struct {
+	Name     string `yaml:"name" json:"name"`
+	Required bool   `yaml:"required,omitempty" json:"required,omitempty"`
+	Default  string `yaml:"default,omitempty" json:"default,omitempty"`
+	Help     string `yaml:"help,omitempty" json:"help,omitempty"`
+}
+
+// FullName returns the macro name prefixed by its namespace
+func (m *Macro) FullName() string {
+	if len(m.Namespace) == 0 {
+		return m.Name
+	}
+	return m.Namespace + "/" + m.Name
+}
+
+// MacroStep is a single step of a macro. In YAML it is either a raw command name or a mapping
+// calling a raw command or another macro
+type MacroStep struct {
+	// ID names the step result, available to the next steps as '{{ .steps.<id> }}'
+	ID       string            `yaml:"id,omitempty" json:"id,omitempty"`
+	Command  string            `yaml:"command,omitempty" json:"command,omitempty"`
+	Method   string            `yaml:"method,omitempty" json:"method,omitempty"`
+	Args     []string          `yaml:"args,omitempty" json:"args,omitempty"`
+	Macro    string            `yaml:"macro,omitempty" json:"macro,omitempty"`
+	Vars     map[string]string `yaml:"vars,omitempty" json:"vars,omitempty"`
+	Parallel []MacroStep       `yaml:"parallel,omitempty" json:"parallel,omitempty"`
+	// Limit is the maximum number of parallel steps running at once, all when 0
+	Limit int `yaml:"limit,omitempty" json:"limit,omitempty"`
+	// Assert holds templates that must render to 'true' for the step to pass.
+	// '{{ .response }}' holds the parsed response and '{{ .status_code }}' the HTTP status code
+	Assert []string `yaml:"assert,omitempty" json:"assert,omitempty"`
+	// Confirm asks for confirmation before running the step. When unset, POST steps are confirmed
+	Confirm *bool `yaml:"confirm,omitempty" json:"confirm,omitempty"`
+	// Save is a template of the file receiving the response body, instead of printing it
+	Save string `yaml:"save,omitempty" json:"save,omitempty"`
+	// Until is a template repeating the step every Interval until it renders to 'true' or Timeout passes.
+	// Like assertions, it sees '{{ .response }}' and '{{ .status_code }}'
+	Until    string `yaml:"until,omitempty" json:"until,omitempty"`
+	Interval string `yaml:"interval,omitempty" json:"interval,omitempty"`
+	Timeout  string `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+}
+
+// pollSettings returns the Interval and Timeout durations, defaulting to 5s and 10m
+func (s *MacroStep) pollSettings() (time.Duration, time.Duration, error) {
+	interval, timeout := 5*time.Second, 10*time.Minute
+	var err error
+	if len(s.Interval) > 0 {
+		if interval, err = time.ParseDuration(s.Interval); err != nil {
+			return 0, 0, fmt.Errorf("invalid interval: %w", err)
+		}
+	}
+	if len(s.Timeout) > 0 {
+		if timeout, err = time.ParseDuration(s.Timeout); err != nil {
+			return 0, 0, fmt.Errorf("invalid timeout: %w", err)
+		}
+	}
+	if interval <= 0 || timeout <= 0 {
+		return 0, 0, fmt.Errorf("interval and timeout must be positive")
+	}
+	return interval, timeout, nil
+}
+
+// method returns the HTTP method the step calls rawCmd with: its own method when set, else the one of rawCmd
+func (s *MacroStep) method(rawCmd *cobra.Command) string {
+	if len(s.Method) > 0 {
+		return strings.ToUpper(s.Method)
+	}
+	return rawCmd.Annotations["method"]
+}
+
+// needsConfirmation returns true when the step must be confirmed before calling rawCmd
+func (s *MacroStep) needsConfirmation(rawCmd *cobra.Command) bool {
+	if s.Confirm != nil {
+		return *s.Confirm
+	}
+	return s.method(rawCmd) == http.MethodPost
+}
+
+// UnmarshalYAML accepts both the short (raw command name) and the long (mapping) step form
+func (s *MacroStep) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var raw interface{}
+	if err := unmarshal(&raw); err != nil {
+		return err
+	}
+	if name, ok := raw.(string); ok {
+		*s = MacroStep{Command: name}
+		return nil
+	}
+	type plainStep MacroStep
+	return unmarshal((*plainStep)(s))
+}
+
+// MarshalYAML emits the short step form when only the raw command name is set
+func (s MacroStep) MarshalYAML() (interface{}, error) {
+	if len(s.Command) > 0 && reflect.DeepEqual(s, MacroStep{Command: s.Command}) {
+		return s.Command, nil
+	}
+	type plainStep MacroStep
+	return plainStep(s), nil
 }
 
 func init() {
 	rootCmd.AddCommand(cmdMacro)
 
-	cmdMacro.PersistentFlags().StringVar(&macroFileName, keyCmdMacroFile, "default-macro.yaml", "Macro file, YAML format")
+	cmdMacro.PersistentFlags().StringSliceVar(
+		&macroFiles,
+		keyCmdMacroFile,
+		[]string{defaultMacroFile},
+		fmt.Sprintf("Macro files, directories or globs, YAML format. Can be specified multiple times. '%s' is always searched", macroSearchPathHelp),
+	)
 
 	config.ViperBindPFlagSet(cmdMacro, cmdMacro.PersistentFlags())
 }
 
+// macroSearchPathHelp describes macroSearchPath independently of the user, keeping the help and schemas reproducible
+const macroSearchPathHelp = "${XDG_CONFIG_HOME:-$HOME/.config}/opnsense-cli/macros.d"
+
+// macroSearchPath returns the standard directory for user macro files
+func macroSearchPath() string {
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if len(configHome) == 0 {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		configHome = filepath.Join(home, ".config")
+	}
+	return filepath.Join(configHome, "opnsense-cli", "macros.d")
+}
+
+// macroFilePaths expands files, directories and globs into a list of macro files
+func macroFilePaths(patterns []string) []string {
+	var paths []string
+	seen := map[string]bool{}
+	add := func(p string) {
+		if !seen[p] {
+			seen[p] = true
+			paths = append(paths, p)
+		}
+	}
+	addDir := func(dir string) {
+		var matches []string
+		for _, ext := range []string{"*.yaml", "*.yml"} {
+			m, _ := filepath.Glob(filepath.Join(dir, ext))
+			matches = append(matches, m...)
+		}
+		sort.Strings(matches)
+		for _, m := range matches {
+			add(m)
+		}
+	}
+
+	for _, p := range patterns {
+		switch {
+		case strings.ContainsAny(p, "*?["):
+			matches, err := filepath.Glob(p)
+			if err != nil {
+				log.Fatalf("Invalid macro file pattern %s: %v", p, err)
+			}
+			if len(matches) == 0 {
+				log.Warnf("No macro files match %s", p)
+			}
+			sort.Strings(matches)
+			for _, m := range matches {
+				if file.IsDirectory(m) {
+					addDir(m)
+					continue
+				}
+				add(m)
+			}
+		case file.IsDirectory(p):
+			addDir(p)
+		case file.IsAccessible(p):
+			add(p)
+		case p == defaultMacroFile:
+			log.Debugf("Default macro file %s is not accessible, skipping", p)
+		default:
+			log.Fatalf("Macro file %s is not accessible", p)
+		}
+	}
+
+	if searchPath := macroSearchPath(); len(searchPath) > 0 && file.IsDirectory(searchPath) {
+		addDir(searchPath)
+	}
+
+	return paths
+}
+
+// loadMacros loads the built-in macros, then the macros from all files.
+// Later files take precedence when names conflict
+func loadMacros(patterns []string) *[]Macro {
+	var macroList []Macro
+	definedIn := map[string]Macro{}
+	sources := [][]Macro{*parseMacros(builtinMacros, builtinMacrosName, builtinMacrosName)}
+	for _, p := range macroFilePaths(patterns) {
+		sources = append(sources, *loadMacroFile(p))
+	}
+	for _, macros := range sources {
+		for _, macro := range macros {
+			previous, ok := definedIn[macro.Name]
+			switch {
+			case !ok || previous.Source == macro.Source:
+			case previous.Source == builtinMacrosName:
+				log.Debugf("Macro '%s' from %s overrides the built-in one", macro.Name, macro.Source)
+			default:
+				log.Warnf(
+					"Macro '%s' from %s overrides the one from %s, use '%s' or '%s' to select one",
+					macro.Name, macro.Source, previous.Source, macro.FullName(), previous.FullName(),
+				)
+			}
+			definedIn[macro.Name] = macro
+			macroList = append(macroList, macro)
+		}
+	}
+	return &macroList
+}
+
 func loadMacroFile(mFile string) *[]Macro {
 	contents, err := os.ReadFile(mFile)
 	if err != nil {
 		log.Fatal(err)
 	}
 
+	return parseMacros(contents, mFile, file.TrimExtension(filepath.Base(mFile)))
+}
+
+// parseMacros parses the macros defined in source, setting their namespace
+func parseMacros(contents []byte, source string, namespace string) *[]Macro {
 	var macroList []Macro
-	err = yaml.UnmarshalWithOptions(contents, &macroList, yaml.Strict())
+	err := yaml.UnmarshalWithOptions(contents, &macroList, yaml.Strict())
 	if err != nil {
-		log.Fatalf("Failed to parse file %s: %v", mFile, err)
+		log.Fatalf("Failed to parse file %s: %v", source, err)
+	}
+
+	for i := range macroList {
+		macroList[i].Namespace = namespace
+		macroList[i].Source = source
 	}
 
 	return &macroList
 }
 
+// findMacro returns the macro with the given name or nil. A plain name selects the last loaded macro,
+// while a namespaced name ('namespace/name') selects the macro from that file
+func findMacro(macroList *[]Macro, name string) *Macro {
+	var found *Macro
+	for i := range *macroList {
+		macro := &(*macroList)[i]
+		if macro.FullName() == name {
+			return macro
+		}
+		if macro.Name == name {
+			found = macro
+		}
+	}
+	return found
+}
+
+// resolveMacroStep returns the macro called by a step, preferring the namespace of the calling macro
+func resolveMacroStep(macroList *[]Macro, caller *Macro, name string) *Macro {
+	if !strings.Contains(name, "/") && len(caller.Namespace) > 0 {
+		if macro := findMacro(macroList, caller.Namespace+"/"+name); macro != nil {
+			return macro
+		}
+	}
+	return findMacro(macroList, name)
+}
+
+// macroCycle returns the chain of macro names leading back to macro, or nil when there is none
+func macroCycle(macroList *[]Macro, macro *Macro, stack []string) []string {
+	for _, name := range stack {
+		if name == macro.FullName() {
+			return append(stack, macro.FullName())
+		}
+	}
+	stack = append(stack, macro.FullName())
+	for _, step := range macro.Commands {
+		if len(step.Macro) == 0 {
+			continue
+		}
+		child := resolveMacroStep(macroList, macro, step.Macro)
+		if child == nil {
+			continue
+		}
+		if cycle := macroCycle(macroList, child, stack); cycle != nil {
+			return cycle
+		}
+	}
+	return nil
+}
+
+// findRawCommand returns the raw subcommand with the given name or nil, also when missing from the selected catalogue
+func findRawCommand(name string) *cobra.Command {
+	for _, cmd := range cmdRawCommand.Commands() {
+		if _, unavailable := cmd.Annotations[annotationRawUnavailable]; unavailable {
+			continue
+		}
+		if cmd.Name() == name {
+			return cmd
+		}
+	}
+	return nil
+}
+
+// macroTemplateFuncs are the functions available in macro templates besides the go template builtins
+var macroTemplateFuncs = template.FuncMap{
+	// now returns the current time, e.g. '{{ now.Format "2006-01-02" }}'
+	"now": time.Now,
+}
+
+// renderTemplate executes text as a go template, failing on missing keys
+func renderTemplate(text string, data map[string]interface{}) (string, error) {
+	tmpl, err := template.New("").Option("missingkey=error").Funcs(macroTemplateFuncs).Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, data); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+// templateFields returns the sorted field chains referenced in text, e.g. [steps status product] for '{{ .steps.status.product }}'
+func templateFields(text string) ([][]string, error) {
+	tmpl, err := template.New("").Funcs(macroTemplateFuncs).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	found := map[string][]string{}
+	if tmpl.Tree != nil {
+		collectTemplateFields(tmpl.Tree.Root, found)
+	}
+	keys := make([]string, 0, len(found))
+	for k := range found {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	fields := make([][]string, 0, len(keys))
+	for _, k := range keys {
+		fields = append(fields, found[k])
+	}
+	return fields, nil
+}
+
+func collectTemplateFields(node parse.Node, found map[string][]string) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			collectTemplateFields(child, found)
+		}
+	case *parse.ActionNode:
+		collectTemplateFields(n.Pipe, found)
+	case *parse.IfNode:
+		collectTemplateFields(&n.BranchNode, found)
+	case *parse.RangeNode:
+		// dot changes inside range and with, only their pipelines refer to the macro variables
+		collectTemplateFields(n.Pipe, found)
+		collectTemplateFields(n.ElseList, found)
+	case *parse.WithNode:
+		collectTemplateFields(n.Pipe, found)
+		collectTemplateFields(n.ElseList, found)
+	case *parse.BranchNode:
+		collectTemplateFields(n.Pipe, found)
+		collectTemplateFields(n.List, found)
+		collectTemplateFields(n.ElseList, found)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for _, c := range n.Cmds {
+			collectTemplateFields(c, found)
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			collectTemplateFields(arg, found)
+		}
+	case *parse.ChainNode:
+		collectTemplateFields(n.Node, found)
+	case *parse.FieldNode:
+		if len(n.Ident) > 0 {
+			found[strings.Join(n.Ident, ".")] = n.Ident
+		}
+	}
+}
+
+// macroVars merges the macro vars and parameter defaults with the overrides
+func macroVars(macro *Macro, overrides map[string]string) map[string]interface{} {
+	vars := make(map[string]interface{}, len(macro.Vars)+len(macro.Params)+len(overrides))
+	for k, v := range macro.Vars {
+		vars[k] = v
+	}
+	for _, p := range macro.Params {
+		if !p.Required {
+			vars[p.Name] = p.Default
+		}
+	}
+	for k, v := range overrides {
+		vars[k] = v
+	}
+	return vars
+}
+
+// isMacroVariable returns true when name is declared in the macro vars or params
+func isMacroVariable(macro *Macro, name string) bool {
+	if _, ok := macro.Vars[name]; ok {
+		return true
+	}
+	for _, p := range macro.Params {
+		if p.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// missingMacroParams returns the names of the required parameters not present in vars
+func missingMacroParams(macro *Macro, vars map[string]interface{}) []string {
+	var missing []string
+	for _, p := range macro.Params {
+		if _, ok := vars[p.Name]; p.Required && !ok {
+			missing = append(missing, p.Name)
+		}
+	}
+	return missing
+}
+
 func RunMacro(cmd *cobra.Command, _ []string) {
 	_ = cmd.Help()
 }
@@ -4,11 +4,21 @@ Copyright © 2023 Dataflows
 package cmd
 
 import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
 	"github.com/goccy/go-yaml"
 	"github.com/spf13/cobra"
 	"github.com/thedataflows/go-commons/pkg/log"
 )
 
+const (
+	keyCmdMacroListOutput = "output"
+)
+
 var (
 	cmdMacroList = &cobra.Command{
 		Use:     "list",
@@ -17,19 +27,48 @@ var (
 		Aliases: []string{"l"},
 		Run:     RunMacroList,
 	}
+
+	macroListOutput string
 )
 
 func init() {
 	cmdMacro.AddCommand(cmdMacroList)
+
+	cmdMacroList.Flags().StringVarP(&macroListOutput, keyCmdMacroListOutput, "o", "table", "Output format, one of: 'table, json, yaml'")
 }
 
-func RunMacroList(cmd *cobra.Command, _ []string) {
-	macroList := loadMacroFile(macroFileName)
+func RunMacroList(_ *cobra.Command, _ []string) {
+	macroList := loadMacros(macroFiles)
 
-	m, err := yaml.MarshalWithOptions(macroList, yaml.Indent(2))
-	if err != nil {
-		log.Fatal(err)
+	switch macroListOutput {
+	case "json":
+		m, err := json.MarshalIndent(macroList, "", "    ")
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println(string(m))
+	case "yaml":
+		m, err := yaml.MarshalWithOptions(macroList, yaml.Indent(2))
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Print(string(m))
+	case "table":
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tDESCRIPTION\tPARAMS\tTAGS")
+		for _, macro := range *macroList {
+			params := make([]string, 0, len(macro.Params))
+			for _, p := range macro.Params {
+				if p.Required {
+					params = append(params, p.Name+"*")
+					continue
+				}
+				params = append(params, p.Name)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", macro.FullName(), macro.Description, strings.Join(params, ","), strings.Join(macro.Tags, ","))
+		}
+		_ = w.Flush()
+	default:
+		log.Fatalf("Unknown output format '%s'", macroListOutput)
 	}
-
-	log.Infof("%s %s:\n%s", cmd.Parent().Name(), cmd.Name(), m)
 }
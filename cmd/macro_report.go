@@ -0,0 +1,244 @@
+/*
+Copyright © 2023 Dataflows
+*/
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/thedataflows/go-commons/pkg/log"
+)
+
+const (
+	macroReportJSON  = "json"
+	macroReportJUnit = "junit"
+
+	// macroReportSummaryLength is the maximum length of the response summary in reports
+	macroReportSummaryLength = 512
+)
+
+// macroReport collects the results of the steps run by 'macro run'
+type macroReport struct {
+	Macros   []string          `json:"macros"`
+	Started  time.Time         `json:"started"`
+	Duration float64           `json:"duration_seconds"`
+	Passed   bool              `json:"passed"`
+	Error    string            `json:"error,omitempty"`
+	Steps    []macroStepReport `json:"steps"`
+}
+
+type macroStepReport struct {
+	Macro      string                 `json:"macro"`
+	Step       string                 `json:"step"`
+	ID         string                 `json:"id,omitempty"`
+	Command    string                 `json:"command"`
+	Method     string                 `json:"method,omitempty"`
+	URL        string                 `json:"url,omitempty"`
+	StatusCode int                    `json:"status_code,omitempty"`
+	Duration   float64                `json:"duration_seconds"`
+	Summary    string                 `json:"response_summary,omitempty"`
+	Assertions []macroAssertionReport `json:"assertions,omitempty"`
+	Passed     bool                   `json:"passed"`
+	Error      string                 `json:"error,omitempty"`
+}
+
+type macroAssertionReport struct {
+	Assertion string `json:"assertion"`
+	Passed    bool   `json:"passed"`
+	Error     string `json:"error,omitempty"`
+}
+
+func newMacroReport(macros []*Macro) *macroReport {
+	r := &macroReport{
+		Started: time.Now(),
+		Steps:   []macroStepReport{},
+	}
+	for _, m := range macros {
+		r.Macros = append(r.Macros, m.FullName())
+	}
+	return r
+}
+
+// add records a step result. Nothing is recorded when no report was requested
+func (r *macroReport) add(step macroStepReport) {
+	if r == nil {
+		return
+	}
+	r.Steps = append(r.Steps, step)
+}
+
+// finish sets the overall outcome of the run
+func (r *macroReport) finish(err error) {
+	r.Duration = time.Since(r.Started).Seconds()
+	r.Passed = err == nil
+	if err != nil {
+		r.Error = err.Error()
+	}
+}
+
+// log logs the outcome of the run as structured fields, following --log-format.
+// With steps, every step result is logged as well
+func (r *macroReport) log(steps bool) {
+	if steps {
+		for _, step := range r.Steps {
+			event := log.Logger.Info()
+			if !step.Passed {
+				event = log.Logger.Error()
+			}
+			event.
+				Str("macro", step.Macro).
+				Str("step", step.Step).
+				Str("id", step.ID).
+				Str("command", step.Command).
+				Str("method", step.Method).
+				Str("url", step.URL).
+				Int("status_code", step.StatusCode).
+				Float64("duration_seconds", step.Duration).
+				Str("response_summary", step.Summary).
+				Bool("passed", step.Passed).
+				Str("error", step.Error).
+				Msg("Macro step finished")
+		}
+	}
+
+	event := log.Logger.Info()
+	if !r.Passed {
+		event = log.Logger.Error()
+	}
+	event.
+		Strs("macros", r.Macros).
+		Time("started", r.Started).
+		Float64("duration_seconds", r.Duration).
+		Int("steps", len(r.Steps)).
+		Bool("passed", r.Passed).
+		Str("error", r.Error).
+		Msg("Macro run finished")
+}
+
+// write saves the report in the given format
+func (r *macroReport) write(format string, fileName string) error {
+	var (
+		contents []byte
+		err      error
+	)
+	switch format {
+	case macroReportJSON:
+		contents, err = json.MarshalIndent(r, "", "    ")
+	case macroReportJUnit:
+		contents, err = r.junit()
+	default:
+		return fmt.Errorf("unknown report format '%s'", format)
+	}
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Clean(fileName), contents, 0o600)
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Time      string          `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr"`
+	Cases     []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// junit renders the report as JUnit XML, one test suite per macro and one test case per step
+func (r *macroReport) junit() ([]byte, error) {
+	suites := junitTestSuites{
+		Name: "opnsense-cli macro run",
+		Time: fmt.Sprintf("%.3f", r.Duration),
+	}
+	index := map[string]int{}
+	suiteTimes := []float64{}
+	for _, step := range r.Steps {
+		i, ok := index[step.Macro]
+		if !ok {
+			i = len(suites.Suites)
+			index[step.Macro] = i
+			suites.Suites = append(suites.Suites, junitTestSuite{
+				Name:      step.Macro,
+				Timestamp: r.Started.Format(time.RFC3339),
+			})
+			suiteTimes = append(suiteTimes, 0)
+		}
+		suite := &suites.Suites[i]
+
+		tc := junitTestCase{
+			Name:      fmt.Sprintf("%s %s", step.Step, step.Command),
+			ClassName: step.Macro,
+			Time:      fmt.Sprintf("%.3f", step.Duration),
+			SystemOut: fmt.Sprintf("%s %s\nstatus: %d\n%s", step.Method, step.URL, step.StatusCode, step.Summary),
+		}
+		if !step.Passed {
+			details := []string{}
+			for _, a := range step.Assertions {
+				if !a.Passed {
+					details = append(details, fmt.Sprintf("assertion failed: %s %s", a.Assertion, a.Error))
+				}
+			}
+			tc.Failure = &junitFailure{Message: step.Error, Text: strings.Join(details, "\n")}
+			suite.Failures++
+			suites.Failures++
+		}
+		suite.Tests++
+		suites.Tests++
+		suiteTimes[i] += step.Duration
+		suite.Time = fmt.Sprintf("%.3f", suiteTimes[i])
+		suite.Cases = append(suite.Cases, tc)
+	}
+
+	var out bytes.Buffer
+	out.WriteString(xml.Header)
+	enc := xml.NewEncoder(&out)
+	enc.Indent("", "  ")
+	if err := enc.Encode(suites); err != nil {
+		return nil, err
+	}
+	out.WriteString("\n")
+	return out.Bytes(), nil
+}
+
+// responseSummary returns the compacted response body, truncated to macroReportSummaryLength
+func responseSummary(body []byte) string {
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, body); err != nil {
+		compact.Reset()
+		compact.Write(bytes.TrimSpace(body))
+	}
+	summary := compact.String()
+	if len(summary) > macroReportSummaryLength {
+		summary = summary[:macroReportSummaryLength] + "..."
+	}
+	return summary
+}
@@ -4,52 +4,440 @@ Copyright © 2023 Dataflows
 package cmd
 
 import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
 	"github.com/spf13/cobra"
 	"github.com/thedataflows/go-commons/pkg/log"
+	"github.com/thedataflows/opnsense-cli/pkg/api"
+)
+
+const (
+	keyCmdMacroRunVar        = "var"
+	keyCmdMacroRunReport     = "report"
+	keyCmdMacroRunReportFile = "report-file"
+	keyCmdMacroRunEvery      = "every"
 )
 
 var (
 	cmdMacroRun = &cobra.Command{
-		Use:     "run",
-		Short:   "Run a predefined macro",
-		Long:    ``,
+		Use:     "run <macro>... [-- args...]",
+		Short:   "Run one or more predefined macros",
+		Long:    "Run one or more predefined macros in order.\n\nWithout '--', the first argument is the macro name and the rest are passed to its commands.",
 		Aliases: []string{"r"},
 		Run:     RunMacroRun,
 	}
+
+	macroRunVars       map[string]string
+	macroRunReportType string
+	macroRunReportFile string
+	macroRunEvery      time.Duration
+	// macroRunReport collects the step results of the current run
+	macroRunReport *macroReport
+	// macroQuiet suppresses printing the responses, the daemon logs the step results instead
+	macroQuiet bool
 )
 
 func init() {
 	cmdMacro.AddCommand(cmdMacroRun)
+
+	cmdMacroRun.Flags().StringToStringVar(&macroRunVars, keyCmdMacroRunVar, nil, "Set macro variables, e.g. --var uuid=abc. Can be specified multiple times")
+	cmdMacroRun.Flags().StringVar(&macroRunReportType, keyCmdMacroRunReport, "", fmt.Sprintf("Write a report of all steps, one of: '%s, %s'", macroReportJSON, macroReportJUnit))
+	cmdMacroRun.Flags().StringVar(&macroRunReportFile, keyCmdMacroRunReportFile, "", "Report file. Defaults to 'macro-report.json' or 'macro-report.xml'")
+	cmdMacroRun.Flags().DurationVar(&macroRunEvery, keyCmdMacroRunEvery, 0, "Run the macros repeatedly at this interval, e.g. 5m, until interrupted. Failed runs are logged and do not stop the loop")
 }
 
-func RunMacroRun(_ *cobra.Command, args []string) {
-	macroList := loadMacroFile(macroFileName)
+func RunMacroRun(cmd *cobra.Command, args []string) {
+	macroList := loadMacros(macroFiles)
 
-	if len(args) == 0 {
+	if len(args) == 0 || cmd.ArgsLenAtDash() == 0 {
 		log.Error("No macro names specified, select at least one from the list")
 		cmdMacroList.Run(cmdMacroList, args)
 		return
 	}
 
-	found := false
-	for _, macro := range *macroList {
-		if macro.Name == args[0] {
-			found = true
-			log.Infof("Running macro '%s'", macro.Name)
-			for _, command := range macro.Commands {
-				// log.Infof("Running command '%s'", command)
-				for _, cmd := range cmdRawCommand.Commands() {
-					if cmd.Name() == command {
-						cmd.Run(cmd, args[1:])
-						break
-					}
+	if err := selectRawCatalog(cmd, true); err != nil {
+		log.Fatal(err)
+	}
+
+	names, cmdArgs := args[:1], args[1:]
+	if dash := cmd.ArgsLenAtDash(); dash > 0 {
+		names, cmdArgs = args[:dash], args[dash:]
+	}
+
+	macros := make([]*Macro, 0, len(names))
+	for _, name := range names {
+		macro := findMacro(macroList, name)
+		if macro == nil {
+			log.Fatalf("Macro '%s' not found", name)
+		}
+		problems := validateMacroCalls(macroList, macro)
+		if len(problems) > 0 {
+			for _, p := range problems {
+				log.Error(p)
+			}
+			log.Fatalf("Refusing to run invalid macro '%s'", macro.FullName())
+		}
+		macros = append(macros, macro)
+	}
+
+	switch macroRunReportType {
+	case "":
+	case macroReportJSON, macroReportJUnit:
+		if len(macroRunReportFile) == 0 {
+			macroRunReportFile = "macro-report.json"
+			if macroRunReportType == macroReportJUnit {
+				macroRunReportFile = "macro-report.xml"
+			}
+		}
+	default:
+		log.Fatalf("Unknown report format '%s'", macroRunReportType)
+	}
+
+	if macroRunEvery <= 0 {
+		if err := runMacroJob(macroList, macros, macroRunVars, cmdArgs); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ticker := time.NewTicker(macroRunEvery)
+	defer ticker.Stop()
+	log.Infof("Running every %s until interrupted", macroRunEvery)
+	for {
+		_ = runMacroJob(macroList, macros, macroRunVars, cmdArgs)
+		macroRunReport.log(false)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runMacroJob runs the macros in order, stopping at the first failure.
+// The step results are collected in macroRunReport, written to a file when requested
+func runMacroJob(macroList *[]Macro, macros []*Macro, overrides map[string]string, args []string) error {
+	macroRunReport = newMacroReport(macros)
+	var err error
+	for _, macro := range macros {
+		if err = runMacro(macroList, macro, overrides, args, nil); err != nil {
+			break
+		}
+	}
+	macroRunReport.finish(err)
+
+	if len(macroRunReportType) > 0 {
+		if reportErr := macroRunReport.write(macroRunReportType, macroRunReportFile); reportErr != nil {
+			log.Errorf("Failed to write report %s: %v", macroRunReportFile, reportErr)
+		} else {
+			log.Infof("Report written to %s", macroRunReportFile)
+		}
+	}
+	return err
+}
+
+// runMacro runs the macro steps in order. stack holds the names of the calling macros
+func runMacro(macroList *[]Macro, macro *Macro, overrides map[string]string, args []string, stack []string) error {
+	for _, name := range stack {
+		if name == macro.FullName() {
+			return fmt.Errorf("macro '%s' calls itself: %s -> %s", macro.FullName(), strings.Join(stack, " -> "), macro.FullName())
+		}
+	}
+	stack = append(stack, macro.FullName())
+
+	vars := macroVars(macro, overrides)
+	if missing := missingMacroParams(macro, vars); len(missing) > 0 {
+		return fmt.Errorf("macro '%s': missing required parameter(s) %s, set with --var", macro.FullName(), strings.Join(missing, ", "))
+	}
+	results := map[string]interface{}{}
+	vars[macroStepsVar] = results
+
+	log.Infof("Running macro '%s'", strings.Join(stack, " -> "))
+	for i, step := range macro.Commands {
+		label := fmt.Sprintf("%d", i+1)
+		switch {
+		case len(step.Macro) > 0:
+			child := resolveMacroStep(macroList, macro, step.Macro)
+			if child == nil {
+				return fmt.Errorf("macro '%s': macro '%s' not found", macro.FullName(), step.Macro)
+			}
+			childOverrides := make(map[string]string, len(overrides)+len(step.Vars))
+			for k, v := range overrides {
+				childOverrides[k] = v
+			}
+			for k, v := range step.Vars {
+				rendered, err := renderTemplate(v, vars)
+				if err != nil {
+					return fmt.Errorf("macro '%s', variable '%s': %w", macro.FullName(), k, err)
+				}
+				childOverrides[k] = rendered
+			}
+			if err := runMacro(macroList, child, childOverrides, args, stack); err != nil {
+				return err
+			}
+		case len(step.Parallel) > 0:
+			responses, err := runMacroParallel(macro, step, vars, args, label)
+			for i, resp := range responses {
+				if resp == nil {
+					continue
+				}
+				printMacroResponse(step.Parallel[i], resp)
+				if len(step.Parallel[i].ID) > 0 {
+					results[step.Parallel[i].ID] = resp.Data
 				}
 			}
+			if err != nil {
+				return err
+			}
+		default:
+			resp, report, err := runMacroCommand(macro, step, vars, args, label, false)
+			macroRunReport.add(report)
+			if err != nil {
+				return err
+			}
+			printMacroResponse(step, resp)
+			if len(step.ID) > 0 {
+				results[step.ID] = resp.Data
+			}
+		}
+	}
+	return nil
+}
+
+// runMacroParallel runs the steps of a parallel group, at most step.Limit at a time.
+// The responses are returned in the order of the steps, along with the first error in that order
+func runMacroParallel(macro *Macro, step MacroStep, vars map[string]interface{}, args []string, label string) ([]*api.Response, error) {
+	limit := step.Limit
+	if limit <= 0 || limit > len(step.Parallel) {
+		limit = len(step.Parallel)
+	}
+	log.Infof("Running %d steps in parallel, at most %d at a time", len(step.Parallel), limit)
+
+	summary := []string{}
+	for i, s := range step.Parallel {
+		rawCmd, cmdArgs, err := prepareMacroCommand(macro, s, vars, args)
+		if err == nil && s.needsConfirmation(rawCmd) {
+			summary = append(summary, macroCommandSummary(fmt.Sprintf("%s.%d", label, i+1), &s, rawCmd, cmdArgs))
+		}
+	}
+	if len(summary) > 0 {
+		err := confirmAction(fmt.Sprintf("Macro '%s' is about to run in parallel:\n%s", macro.FullName(), strings.Join(summary, "\n")))
+		if err != nil {
+			return nil, fmt.Errorf("macro '%s', step %s: %w", macro.FullName(), label, err)
 		}
 	}
 
-	if !found {
-		log.Warnf("Macro '%s' not found", args[0])
+	responses := make([]*api.Response, len(step.Parallel))
+	reports := make([]macroStepReport, len(step.Parallel))
+	errs := make([]error, len(step.Parallel))
+	sem := make(chan struct{}, limit)
+	var wg sync.WaitGroup
+	for i := range step.Parallel {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			responses[i], reports[i], errs[i] = runMacroCommand(macro, step.Parallel[i], vars, args, fmt.Sprintf("%s.%d", label, i+1), true)
+		}(i)
 	}
+	wg.Wait()
 
+	for _, report := range reports {
+		macroRunReport.add(report)
+	}
+
+	for _, err := range errs {
+		if err != nil {
+			return responses, err
+		}
+	}
+	return responses, nil
+}
+
+// runMacroCommand renders the step arguments, calls its raw command and checks the assertions.
+// label identifies the step in the returned report. Unless confirmed, mutating steps are confirmed first
+func runMacroCommand(macro *Macro, step MacroStep, vars map[string]interface{}, args []string, label string, confirmed bool) (*api.Response, macroStepReport, error) {
+	report := macroStepReport{
+		Macro:   macro.FullName(),
+		Step:    label,
+		ID:      step.ID,
+		Command: step.Command,
+	}
+	started := time.Now()
+	resp, err := runMacroRequest(macro, step, vars, args, confirmed, &report)
+	if err == nil && len(step.Until) > 0 {
+		resp, err = pollMacroCommand(macro, step, vars, args, resp, &report)
+		report.Duration = time.Since(started).Seconds()
+	}
+	if err == nil && len(step.Save) > 0 {
+		err = saveMacroResponse(macro, step, vars, resp)
+	}
+	if resp != nil {
+		report.StatusCode = resp.StatusCode
+		report.Summary = responseSummary(resp.Body)
+	}
+	if err == nil {
+		err = checkMacroAssertions(macro, step, vars, resp, &report)
+	}
+	report.Passed = err == nil
+	if err != nil {
+		report.Error = err.Error()
+	}
+	return resp, report, err
+}
+
+func runMacroRequest(macro *Macro, step MacroStep, vars map[string]interface{}, args []string, confirmed bool, report *macroStepReport) (*api.Response, error) {
+	rawCmd, cmdArgs, err := prepareMacroCommand(macro, step, vars, args)
+	if err != nil {
+		return nil, err
+	}
+	report.Method = step.method(rawCmd)
+	report.URL = rawCommandURL(rawCmd, cmdArgs)
+
+	if !confirmed && step.needsConfirmation(rawCmd) {
+		err := confirmAction(fmt.Sprintf("Macro '%s' is about to run:\n%s", macro.FullName(), macroCommandSummary(report.Step, &step, rawCmd, cmdArgs)))
+		if err != nil {
+			return nil, fmt.Errorf("macro '%s', command '%s': %w", macro.FullName(), step.Command, err)
+		}
+	}
+
+	started := time.Now()
+	resp, err := executeRawCommand(rawCmd, report.Method, cmdArgs, nil)
+	report.Duration = time.Since(started).Seconds()
+	if err != nil {
+		return resp, fmt.Errorf("macro '%s', command '%s': %w", macro.FullName(), step.Command, err)
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		return resp, fmt.Errorf("macro '%s', command '%s': HTTP status %d", macro.FullName(), step.Command, resp.StatusCode)
+	}
+	return resp, nil
+}
+
+// prepareMacroCommand returns the raw command of a step with its rendered arguments
+func prepareMacroCommand(macro *Macro, step MacroStep, vars map[string]interface{}, args []string) (*cobra.Command, []string, error) {
+	cmdArgs := args
+	if len(step.Args) > 0 {
+		cmdArgs = make([]string, 0, len(step.Args))
+		for _, arg := range step.Args {
+			rendered, err := renderTemplate(arg, vars)
+			if err != nil {
+				return nil, nil, fmt.Errorf("macro '%s', command '%s': %w", macro.FullName(), step.Command, err)
+			}
+			cmdArgs = append(cmdArgs, rendered)
+		}
+	}
+	rawCmd := findRawCommand(step.Command)
+	if rawCmd == nil {
+		return nil, nil, fmt.Errorf("macro '%s': %w", macro.FullName(), rawCommandNotFound(step.Command))
+	}
+	return rawCmd, cmdArgs, nil
+}
+
+// macroCommandSummary describes the call of a step for confirmation prompts
+func macroCommandSummary(label string, step *MacroStep, rawCmd *cobra.Command, args []string) string {
+	return fmt.Sprintf("  %s. %s %s (%s)", label, step.method(rawCmd), rawCommandURL(rawCmd, args), rawCmd.Name())
+}
+
+// pollMacroCommand repeats the step until its 'until' condition renders to 'true' or the timeout passes
+func pollMacroCommand(macro *Macro, step MacroStep, vars map[string]interface{}, args []string, resp *api.Response, report *macroStepReport) (*api.Response, error) {
+	interval, timeout, err := step.pollSettings()
+	if err != nil {
+		return resp, fmt.Errorf("macro '%s', command '%s': %w", macro.FullName(), step.Command, err)
+	}
+	deadline := time.Now().Add(timeout)
+	for {
+		rendered, err := renderTemplate(step.Until, macroResponseVars(vars, resp))
+		if err != nil {
+			return resp, fmt.Errorf("macro '%s', command '%s': until: %w", macro.FullName(), step.Command, err)
+		}
+		if strings.TrimSpace(rendered) == "true" {
+			return resp, nil
+		}
+		if time.Now().Add(interval).After(deadline) {
+			return resp, fmt.Errorf("macro '%s', command '%s': '%s' not met within %s", macro.FullName(), step.Command, step.Until, timeout)
+		}
+		log.Infof("Waiting %s for '%s'", interval, step.Until)
+		time.Sleep(interval)
+		if resp, err = runMacroRequest(macro, step, vars, args, true, report); err != nil {
+			return resp, err
+		}
+	}
+}
+
+// saveMacroResponse writes the response body to the file named by the step 'save' template
+func saveMacroResponse(macro *Macro, step MacroStep, vars map[string]interface{}, resp *api.Response) error {
+	fileName, err := renderTemplate(step.Save, vars)
+	if err != nil {
+		return fmt.Errorf("macro '%s', command '%s': save: %w", macro.FullName(), step.Command, err)
+	}
+	fileName = filepath.Clean(fileName)
+	if err := os.MkdirAll(filepath.Dir(fileName), 0o750); err != nil {
+		return fmt.Errorf("macro '%s', command '%s': %w", macro.FullName(), step.Command, err)
+	}
+	if err := os.WriteFile(fileName, resp.Body, 0o600); err != nil {
+		return fmt.Errorf("macro '%s', command '%s': %w", macro.FullName(), step.Command, err)
+	}
+	log.Infof("Saved %s response to %s", step.Command, fileName)
+	return nil
+}
+
+// printMacroResponse prints the response of a step, unless it was saved to a file
+func printMacroResponse(step MacroStep, resp *api.Response) {
+	if len(step.Save) == 0 && !macroQuiet {
+		printAPIResponse(resp)
+	}
+}
+
+// macroResponseVars returns a copy of vars with the response, for assertions and conditions
+func macroResponseVars(vars map[string]interface{}, resp *api.Response) map[string]interface{} {
+	data := make(map[string]interface{}, len(vars)+2)
+	for k, v := range vars {
+		data[k] = v
+	}
+	data[macroResponseVar] = resp.Data
+	data[macroStatusCodeVar] = resp.StatusCode
+	return data
+}
+
+// checkMacroAssertions renders the step assertions with the response and fails unless all are 'true'
+func checkMacroAssertions(macro *Macro, step MacroStep, vars map[string]interface{}, resp *api.Response, report *macroStepReport) error {
+	if len(step.Assert) == 0 {
+		return nil
+	}
+	data := macroResponseVars(vars, resp)
+
+	failed := 0
+	for _, a := range step.Assert {
+		result := macroAssertionReport{Assertion: a}
+		rendered, err := renderTemplate(a, data)
+		switch {
+		case err != nil:
+			result.Error = err.Error()
+		case strings.TrimSpace(rendered) != "true":
+			result.Error = fmt.Sprintf("got '%s'", strings.TrimSpace(rendered))
+		default:
+			result.Passed = true
+		}
+		if !result.Passed {
+			failed++
+			log.Errorf("Macro '%s', command '%s': assertion %s failed: %s", macro.FullName(), step.Command, a, result.Error)
+		}
+		report.Assertions = append(report.Assertions, result)
+	}
+	if failed > 0 {
+		return fmt.Errorf("macro '%s', command '%s': %d of %d assertion(s) failed", macro.FullName(), step.Command, failed, len(step.Assert))
+	}
+	return nil
 }
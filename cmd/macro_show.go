@@ -0,0 +1,169 @@
+/*
+Copyright © 2023 Dataflows
+*/
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/thedataflows/go-commons/pkg/log"
+)
+
+var (
+	cmdMacroShow = &cobra.Command{
+		Use:     "show <macro>",
+		Short:   "Show a predefined macro with its expanded steps",
+		Long:    ``,
+		Aliases: []string{"s"},
+		Args:    cobra.ExactArgs(1),
+		Run:     RunMacroShow,
+	}
+)
+
+func init() {
+	cmdMacro.AddCommand(cmdMacroShow)
+
+	cmdMacroShow.Flags().StringToStringVar(&macroRunVars, keyCmdMacroRunVar, nil, "Set macro variables, e.g. --var uuid=abc. Can be specified multiple times")
+}
+
+func RunMacroShow(cmd *cobra.Command, args []string) {
+	macroList := loadMacros(macroFiles)
+	if err := selectRawCatalog(cmd, false); err != nil {
+		log.Fatal(err)
+	}
+
+	macro := findMacro(macroList, args[0])
+	if macro == nil {
+		log.Fatalf("Macro '%s' not found", args[0])
+	}
+
+	fmt.Printf("Name: %s\n", macro.FullName())
+	fmt.Printf("Source: %s\n", macro.Source)
+	if len(macro.Description) > 0 {
+		fmt.Printf("Description: %s\n", macro.Description)
+	}
+	if len(macro.Tags) > 0 {
+		fmt.Printf("Tags: %s\n", strings.Join(macro.Tags, ", "))
+	}
+	if len(macro.Schedule) > 0 {
+		fmt.Printf("Schedule: %s\n", macro.Schedule)
+	}
+	if len(macro.Params) > 0 {
+		fmt.Println("Params:")
+		for _, p := range macro.Params {
+			attrs := []string{}
+			if p.Required {
+				attrs = append(attrs, "required")
+			}
+			if len(p.Default) > 0 {
+				attrs = append(attrs, fmt.Sprintf("default: %s", p.Default))
+			}
+			line := fmt.Sprintf("  %s", p.Name)
+			if len(attrs) > 0 {
+				line = fmt.Sprintf("%s (%s)", line, strings.Join(attrs, ", "))
+			}
+			if len(p.Help) > 0 {
+				line = fmt.Sprintf("%s: %s", line, p.Help)
+			}
+			fmt.Println(line)
+		}
+	}
+	fmt.Println("Steps:")
+	showMacroSteps(macroList, macro, macroRunVars, "  ", nil)
+}
+
+// showMacroSteps prints the steps of a macro, expanding the called macros
+func showMacroSteps(macroList *[]Macro, macro *Macro, overrides map[string]string, indent string, stack []string) {
+	for _, name := range stack {
+		if name == macro.FullName() {
+			fmt.Printf("%s! recursive call to macro '%s'\n", indent, macro.FullName())
+			return
+		}
+	}
+	stack = append(stack, macro.FullName())
+
+	vars := macroVars(macro, overrides)
+	for _, name := range missingMacroParams(macro, vars) {
+		vars[name] = fmt.Sprintf("<%s>", name)
+	}
+
+	for i, step := range macro.Commands {
+		if len(step.Macro) > 0 {
+			fmt.Printf("%s%d. macro %s\n", indent, i+1, step.Macro)
+			child := resolveMacroStep(macroList, macro, step.Macro)
+			if child == nil {
+				fmt.Printf("%s   ! macro not found\n", indent)
+				continue
+			}
+			childOverrides := make(map[string]string, len(overrides)+len(step.Vars))
+			for k, v := range overrides {
+				childOverrides[k] = v
+			}
+			for k, v := range step.Vars {
+				childOverrides[k] = renderTemplateOrText(v, vars)
+			}
+			showMacroSteps(macroList, child, childOverrides, indent+"   ", stack)
+			continue
+		}
+
+		if len(step.Parallel) > 0 {
+			limit := "all"
+			if step.Limit > 0 {
+				limit = fmt.Sprintf("%d", step.Limit)
+			}
+			fmt.Printf("%s%d. parallel, %s at a time\n", indent, i+1, limit)
+			for j, s := range step.Parallel {
+				showMacroCommand(&s, vars, fmt.Sprintf("%s   %d.%d.", indent, i+1, j+1), indent+"   ")
+			}
+			continue
+		}
+
+		showMacroCommand(&step, vars, fmt.Sprintf("%s%d.", indent, i+1), indent)
+	}
+}
+
+// showMacroCommand prints a command step with its resolved method and URL
+func showMacroCommand(step *MacroStep, vars map[string]interface{}, prefix string, indent string) {
+	title := step.Command
+	if len(step.ID) > 0 {
+		title = fmt.Sprintf("%s (id: %s)", title, step.ID)
+	}
+	rawCmd := findRawCommand(step.Command)
+	if rawCmd == nil {
+		fmt.Printf("%s %s\n%s   ! command not found\n", prefix, title, indent)
+		return
+	}
+	args := make([]string, 0, len(step.Args))
+	for _, arg := range step.Args {
+		args = append(args, renderTemplateOrText(arg, vars))
+	}
+	url := rawCommandURL(rawCmd, args)
+	if len(step.Args) == 0 && len(rawCommandParameters(rawCmd)) > 0 {
+		url = fmt.Sprintf("%s/<run arguments>", url)
+	}
+	if step.needsConfirmation(rawCmd) {
+		title = fmt.Sprintf("%s [confirm]", title)
+	}
+	fmt.Printf("%s %s\n%s   %s %s\n", prefix, title, indent, step.method(rawCmd), url)
+	if len(step.Until) > 0 {
+		interval, timeout, _ := step.pollSettings()
+		fmt.Printf("%s   until %s (every %s, timeout %s)\n", indent, step.Until, interval, timeout)
+	}
+	if len(step.Save) > 0 {
+		fmt.Printf("%s   save to %s\n", indent, renderTemplateOrText(step.Save, vars))
+	}
+	for _, a := range step.Assert {
+		fmt.Printf("%s   assert %s\n", indent, a)
+	}
+}
+
+// renderTemplateOrText renders text, falling back to the unrendered text on errors
+func renderTemplateOrText(text string, vars map[string]interface{}) string {
+	rendered, err := renderTemplate(text, vars)
+	if err != nil {
+		return text
+	}
+	return rendered
+}
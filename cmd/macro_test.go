@@ -0,0 +1,300 @@
+package cmd
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/goccy/go-yaml"
+)
+
+func TestParseMacros(t *testing.T) {
+	contents := []byte(`
+- name: status
+  vars:
+    uuid: abc
+  commands:
+    - core/firmware/status
+    - id: item
+      command: firewall/alias/getItem
+      method: post
+      args: ['{{ .uuid }}']
+      assert: ['{{ eq .status_code 200 }}']
+    - macro: other
+      vars:
+        name: '{{ .steps.item.alias.name }}'
+    - parallel:
+        - firewall/alias/searchItem
+        - core/firmware/status
+      limit: 1
+`)
+	want := []Macro{{
+		Name: "status",
+		Vars: map[string]string{"uuid": "abc"},
+		Commands: []MacroStep{
+			{Command: "core/firmware/status"},
+			{ID: "item", Command: "firewall/alias/getItem", Method: "post", Args: []string{"{{ .uuid }}"}, Assert: []string{"{{ eq .status_code 200 }}"}},
+			{Macro: "other", Vars: map[string]string{"name": "{{ .steps.item.alias.name }}"}},
+			{Parallel: []MacroStep{{Command: "firewall/alias/searchItem"}, {Command: "core/firmware/status"}}, Limit: 1},
+		},
+		Namespace: "mymacros",
+		Source:    "mymacros.yaml",
+	}}
+	got := parseMacros(contents, "mymacros.yaml", "mymacros")
+	if !reflect.DeepEqual(*got, want) {
+		t.Errorf("parseMacros() = %+v, want %+v", *got, want)
+	}
+
+	// the builtin macros are parsed strictly too, and must be valid against the embedded catalogue
+	builtin := parseMacros(builtinMacros, "builtin.yaml", "builtin")
+	if len(*builtin) == 0 {
+		t.Error("parseMacros() found no builtin macros")
+	}
+	for _, err := range validateMacros(builtin) {
+		t.Errorf("builtin macro: %v", err)
+	}
+}
+
+func TestMacroStepMarshalYAML(t *testing.T) {
+	confirm := false
+	tests := []struct {
+		name string
+		step MacroStep
+		want string
+	}{
+		{name: "short form", step: MacroStep{Command: "core/firmware/status"}, want: "core/firmware/status\n"},
+		{name: "args", step: MacroStep{Command: "firewall/alias/getItem", Args: []string{"abc"}}, want: "command: firewall/alias/getItem\nargs:\n- abc\n"},
+		{name: "confirm", step: MacroStep{Command: "core/firmware/update", Confirm: &confirm}, want: "command: core/firmware/update\nconfirm: false\n"},
+		{name: "macro", step: MacroStep{Macro: "builtin/firmware-update"}, want: "macro: builtin/firmware-update\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := yaml.Marshal(tt.step)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(out) != tt.want {
+				t.Errorf("Marshal() = %q, want %q", out, tt.want)
+			}
+			var step MacroStep
+			if err := yaml.UnmarshalWithOptions(out, &step, yaml.Strict()); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(step, tt.step) {
+				t.Errorf("Unmarshal(Marshal()) = %+v, want %+v", step, tt.step)
+			}
+		})
+	}
+}
+
+func TestMacroStepPollSettings(t *testing.T) {
+	tests := []struct {
+		name     string
+		step     MacroStep
+		interval string
+		timeout  string
+		wantErr  string
+	}{
+		{name: "defaults", interval: "5s", timeout: "10m0s"},
+		{name: "set", step: MacroStep{Interval: "1s", Timeout: "1m"}, interval: "1s", timeout: "1m0s"},
+		{name: "invalid interval", step: MacroStep{Interval: "often"}, wantErr: "invalid interval"},
+		{name: "invalid timeout", step: MacroStep{Timeout: "later"}, wantErr: "invalid timeout"},
+		{name: "negative", step: MacroStep{Interval: "-1s"}, wantErr: "interval and timeout must be positive"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			interval, timeout, err := tt.step.pollSettings()
+			if len(tt.wantErr) > 0 {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("pollSettings() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if interval.String() != tt.interval || timeout.String() != tt.timeout {
+				t.Errorf("pollSettings() = %s, %s, want %s, %s", interval, timeout, tt.interval, tt.timeout)
+			}
+		})
+	}
+}
+
+func TestValidateMacro(t *testing.T) {
+	tests := []struct {
+		name   string
+		macros string
+		want   []string
+	}{
+		{
+			name: "valid",
+			macros: `
+- name: aliases
+  params:
+    - name: uuid
+      required: true
+  commands:
+    - id: item
+      command: firewall/alias/getItem
+      args: ['{{ .uuid }}']
+    - command: firewall/alias/searchItem
+      method: get
+      until: '{{ eq .status_code 200 }}'
+      interval: 1s
+    - macro: status
+      vars:
+        name: '{{ .steps.item.alias.name }}'
+- name: status
+  schedule: '@every 1h'
+  commands:
+    - parallel:
+        - core/firmware/status
+        - firewall/alias/searchItem
+      limit: 1
+`,
+		},
+		{
+			name: "macro definition",
+			macros: `
+- name: broken
+  schedule: 'every day'
+  params:
+    - name: uuid
+      required: true
+      default: abc
+    - name: uuid
+  vars:
+    steps: x
+  commands: []
+`,
+			want: []string{
+				"macro 'test/broken' has no commands",
+				"macro 'test/broken' has required parameter 'uuid' with a default value",
+				"macro 'test/broken' has parameter 'uuid' defined more than once",
+				"macro 'test/broken' has an invalid schedule 'every day'",
+				"macro 'test/broken' cannot define reserved variable 'steps'",
+			},
+		},
+		{
+			name: "raw command steps",
+			macros: `
+- name: broken
+  commands:
+    - firewall/alias/missing
+    - command: firewall/alias/setItem
+      method: get
+    - command: firewall/alias/setItem
+      args: [a, b]
+    - command: firewall/alias/getItem
+      args: ['{{ .uuid }}']
+      assert: ['{{ .steps.later }}']
+      interval: 1s
+    - id: later
+      command: firewall/alias/searchItem
+      vars:
+        a: b
+      until: '{{ .done }}'
+      timeout: soon
+`,
+			want: []string{
+				"macro 'test/broken', step 1: unknown command 'firewall/alias/missing'",
+				"macro 'test/broken', step 2: command 'firewall/alias/setItem' expects method POST, not GET",
+				"macro 'test/broken', step 3: command 'firewall/alias/setItem' accepts at most 1 argument(s) [$uuid], got 2",
+				"macro 'test/broken', step 4: argument '{{ .uuid }}' references undefined variable 'uuid'",
+				"macro 'test/broken', step 4: assertion '{{ .steps.later }}' references the result of step 'later', which is not run before",
+				"macro 'test/broken', step 4: interval and timeout are only allowed with until",
+				"macro 'test/broken', step 5: vars are only allowed on macro steps",
+				"macro 'test/broken', step 5: until '{{ .done }}' references undefined variable 'done'",
+				"macro 'test/broken', step 5: invalid timeout",
+			},
+		},
+		{
+			name: "macro and parallel steps",
+			macros: `
+- name: broken
+  commands:
+    - {}
+    - command: core/firmware/status
+      macro: broken
+    - macro: missing
+      args: [a]
+    - macro: broken
+    - command: core/firmware/status
+      limit: 1
+    - parallel:
+        - macro: broken
+        - id: a
+          command: core/firmware/status
+        - id: a
+          command: core/firmware/status
+      limit: -1
+      id: b
+`,
+			want: []string{
+				"macro 'test/broken', step 1: step has neither command, macro nor parallel",
+				"macro 'test/broken', step 2: step must have only one of command, macro or parallel",
+				"macro 'test/broken', step 3: only vars are allowed along macro step 'missing'",
+				"macro 'test/broken', step 3: unknown macro 'missing'",
+				"macro 'test/broken', step 5: limit is only allowed on parallel steps",
+				"macro 'test/broken', step 6: parallel limit must not be negative",
+				"macro 'test/broken', step 6: only limit is allowed along parallel, set the other options on the grouped steps",
+				"macro 'test/broken', step 6: parallel step 1: only command steps can run in parallel",
+				"macro 'test/broken', step 6: id 'a' is used more than once",
+				"macro 'test/broken' has a recursive macro chain: test/broken -> test/broken",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			macroList := parseMacros([]byte(tt.macros), "test.yaml", "test")
+			var got []string
+			for _, err := range validateMacros(macroList) {
+				got = append(got, err.Error())
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("validateMacros() = %q, want %q", got, tt.want)
+			}
+			for i := range got {
+				if !strings.HasPrefix(got[i], tt.want[i]) {
+					t.Errorf("validateMacros()[%d] = %q, want %q", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestValidateMacroCalls(t *testing.T) {
+	macroList := parseMacros([]byte(`
+- name: a
+  commands:
+    - core/firmware/status
+    - macro: b
+- name: b
+  commands:
+    - macro: c
+    - macro: c
+- name: c
+  commands:
+    - firewall/alias/missing
+    - command: firewall/alias/setItem
+      args: [a, b]
+- name: unrelated
+  commands:
+    - firewall/alias/missing
+`), "test.yaml", "test")
+	want := []string{
+		"macro 'test/c', step 1: unknown command 'firewall/alias/missing'",
+		"macro 'test/c', step 2: command 'firewall/alias/setItem' accepts at most 1 argument(s) [$uuid], got 2",
+	}
+	var got []string
+	for _, err := range validateMacroCalls(macroList, findMacro(macroList, "a")) {
+		got = append(got, err.Error())
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("validateMacroCalls() = %q, want %q", got, want)
+	}
+	// validateMacro only checks the steps of the macro itself
+	if problems := validateMacro(macroList, findMacro(macroList, "a")); len(problems) > 0 {
+		t.Errorf("validateMacro() = %v, want none", problems)
+	}
+}
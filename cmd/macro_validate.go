@@ -0,0 +1,281 @@
+/*
+Copyright © 2023 Dataflows
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/robfig/cron/v3"
+	"github.com/spf13/cobra"
+	"github.com/thedataflows/go-commons/pkg/log"
+)
+
+var (
+	cmdMacroValidate = &cobra.Command{
+		Use:     "validate",
+		Short:   "Validate predefined macros against the raw commands",
+		Long:    ``,
+		Aliases: []string{"v"},
+		Run:     RunMacroValidate,
+	}
+)
+
+func init() {
+	cmdMacro.AddCommand(cmdMacroValidate)
+}
+
+func RunMacroValidate(cmd *cobra.Command, _ []string) {
+	macroList := loadMacros(macroFiles)
+	// validate against the catalogue of --opnsense-version, the latest one by default
+	if err := selectRawCatalog(cmd, false); err != nil {
+		log.Fatal(err)
+	}
+
+	problems := validateMacros(macroList)
+	for _, p := range problems {
+		log.Error(p)
+	}
+	if len(problems) > 0 {
+		log.Errorf("%d problem(s) found", len(problems))
+		os.Exit(1)
+	}
+
+	log.Infof("%d macro(s) are valid", len(*macroList))
+}
+
+// validateMacros checks all macros and reports duplicated names
+func validateMacros(macroList *[]Macro) []error {
+	var problems []error
+	seen := map[string]bool{}
+	for i := range *macroList {
+		macro := &(*macroList)[i]
+		if seen[macro.FullName()] {
+			problems = append(problems, fmt.Errorf("macro '%s' is defined more than once in %s", macro.Name, macro.Source))
+		}
+		seen[macro.FullName()] = true
+		problems = append(problems, validateMacro(macroList, macro)...)
+	}
+	return problems
+}
+
+// validateMacroCalls checks a macro and the macros it calls, directly or through other macros, so that none of
+// their steps runs when a step further down the chain is invalid
+func validateMacroCalls(macroList *[]Macro, macro *Macro) []error {
+	var problems []error
+	validated := map[*Macro]bool{}
+	var walk func(macro *Macro, steps []MacroStep)
+	walk = func(macro *Macro, steps []MacroStep) {
+		for _, step := range steps {
+			walk(macro, step.Parallel)
+			if len(step.Macro) == 0 {
+				continue
+			}
+			if child := resolveMacroStep(macroList, macro, step.Macro); child != nil && !validated[child] {
+				validated[child] = true
+				problems = append(problems, validateMacro(macroList, child)...)
+				walk(child, child.Commands)
+			}
+		}
+	}
+	validated[macro] = true
+	problems = append(problems, validateMacro(macroList, macro)...)
+	walk(macro, macro.Commands)
+	return problems
+}
+
+// validateMacro checks every step of a macro against the loaded raw commands and macros
+func validateMacro(macroList *[]Macro, macro *Macro) []error {
+	var problems []error
+	if len(macro.Name) == 0 {
+		problems = append(problems, fmt.Errorf("macro without name in %s", macro.Source))
+	}
+	if len(macro.Commands) == 0 {
+		problems = append(problems, fmt.Errorf("macro '%s' has no commands", macro.FullName()))
+	}
+	params := map[string]bool{}
+	for _, p := range macro.Params {
+		switch {
+		case len(p.Name) == 0:
+			problems = append(problems, fmt.Errorf("macro '%s' has a parameter without name", macro.FullName()))
+		case params[p.Name]:
+			problems = append(problems, fmt.Errorf("macro '%s' has parameter '%s' defined more than once", macro.FullName(), p.Name))
+		case p.Required && len(p.Default) > 0:
+			problems = append(problems, fmt.Errorf("macro '%s' has required parameter '%s' with a default value", macro.FullName(), p.Name))
+		}
+		if _, ok := macro.Vars[p.Name]; ok {
+			problems = append(problems, fmt.Errorf("macro '%s' has parameter '%s' also defined in vars", macro.FullName(), p.Name))
+		}
+		params[p.Name] = true
+	}
+	if len(macro.Schedule) > 0 {
+		if _, err := cron.ParseStandard(macro.Schedule); err != nil {
+			problems = append(problems, fmt.Errorf("macro '%s' has an invalid schedule '%s': %w", macro.FullName(), macro.Schedule, err))
+		}
+	}
+	for _, reserved := range []string{macroStepsVar, macroResponseVar, macroStatusCodeVar} {
+		if _, ok := macro.Vars[reserved]; ok || params[reserved] {
+			problems = append(problems, fmt.Errorf("macro '%s' cannot define reserved variable '%s'", macro.FullName(), reserved))
+		}
+	}
+	// ids of the previous steps, which can be referenced in templates
+	ids := map[string]bool{}
+	for i, step := range macro.Commands {
+		for _, err := range validateMacroStep(macroList, macro, &step, ids) {
+			problems = append(problems, fmt.Errorf("macro '%s', step %d: %w", macro.FullName(), i+1, err))
+		}
+		for _, id := range macroStepIDs(&step) {
+			if ids[id] {
+				problems = append(problems, fmt.Errorf("macro '%s', step %d: id '%s' is used more than once", macro.FullName(), i+1, id))
+			}
+			ids[id] = true
+		}
+	}
+	if cycle := macroCycle(macroList, macro, nil); cycle != nil {
+		problems = append(problems, fmt.Errorf("macro '%s' has a recursive macro chain: %s", macro.FullName(), strings.Join(cycle, " -> ")))
+	}
+	return problems
+}
+
+func validateMacroStep(macroList *[]Macro, macro *Macro, step *MacroStep, ids map[string]bool) []error {
+	var problems []error
+	kinds := 0
+	for _, set := range []bool{len(step.Command) > 0, len(step.Macro) > 0, len(step.Parallel) > 0} {
+		if set {
+			kinds++
+		}
+	}
+	switch {
+	case kinds == 0:
+		return append(problems, fmt.Errorf("step has neither command, macro nor parallel"))
+	case kinds > 1:
+		return append(problems, fmt.Errorf("step must have only one of command, macro or parallel"))
+	}
+	if step.Limit != 0 && len(step.Parallel) == 0 {
+		problems = append(problems, fmt.Errorf("limit is only allowed on parallel steps"))
+	}
+
+	if len(step.Parallel) > 0 {
+		if step.Limit < 0 {
+			problems = append(problems, fmt.Errorf("parallel limit must not be negative"))
+		}
+		if !reflect.DeepEqual(*step, MacroStep{Parallel: step.Parallel, Limit: step.Limit}) {
+			problems = append(problems, fmt.Errorf("only limit is allowed along parallel, set the other options on the grouped steps"))
+		}
+		for i, s := range step.Parallel {
+			if len(s.Command) == 0 {
+				problems = append(problems, fmt.Errorf("parallel step %d: only command steps can run in parallel", i+1))
+				continue
+			}
+			for _, err := range validateMacroStep(macroList, macro, &s, ids) {
+				problems = append(problems, fmt.Errorf("parallel step %d: %w", i+1, err))
+			}
+		}
+		return problems
+	}
+
+	if len(step.Macro) > 0 {
+		if !reflect.DeepEqual(*step, MacroStep{Macro: step.Macro, Vars: step.Vars}) {
+			problems = append(problems, fmt.Errorf("only vars are allowed along macro step '%s'", step.Macro))
+		}
+		if resolveMacroStep(macroList, macro, step.Macro) == nil {
+			problems = append(problems, fmt.Errorf("unknown macro '%s'", step.Macro))
+		}
+		names := make([]string, 0, len(step.Vars))
+		for name := range step.Vars {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			problems = append(problems, validateTemplateVariables(macro, fmt.Sprintf("variable '%s'", name), step.Vars[name], ids)...)
+		}
+		return problems
+	}
+
+	if len(step.Vars) > 0 {
+		problems = append(problems, fmt.Errorf("vars are only allowed on macro steps"))
+	}
+	rawCmd := findRawCommand(step.Command)
+	if rawCmd == nil {
+		return append(problems, rawCommandNotFound(step.Command))
+	}
+	// check the method the step is run with, as accepted by the controller
+	methods := strings.Split(rawCmd.Annotations["methods"], ",")
+	if method := step.method(rawCmd); !contains(methods, method) {
+		problems = append(problems, fmt.Errorf("command '%s' expects method %s, not %s", step.Command, strings.Join(methods, " or "), method))
+	}
+	parameters := rawCommandParameters(rawCmd)
+	if len(step.Args) > 0 && len(step.Args) < len(parameters) {
+		problems = append(problems, fmt.Errorf("command '%s' expects at least %d argument(s) %v, got %d", step.Command, len(parameters), parameters, len(step.Args)))
+	}
+	if len(step.Args) > len(parameters) {
+		problems = append(problems, fmt.Errorf("command '%s' accepts at most %d argument(s) %v, got %d", step.Command, len(parameters), parameters, len(step.Args)))
+	}
+	for _, arg := range step.Args {
+		problems = append(problems, validateTemplateVariables(macro, fmt.Sprintf("argument '%s'", arg), arg, ids)...)
+	}
+	for _, a := range step.Assert {
+		problems = append(problems, validateTemplateVariables(macro, fmt.Sprintf("assertion '%s'", a), a, ids, macroResponseVar, macroStatusCodeVar)...)
+	}
+	if len(step.Until) > 0 {
+		problems = append(problems, validateTemplateVariables(macro, fmt.Sprintf("until '%s'", step.Until), step.Until, ids, macroResponseVar, macroStatusCodeVar)...)
+		if _, _, err := step.pollSettings(); err != nil {
+			problems = append(problems, err)
+		}
+	} else if len(step.Interval) > 0 || len(step.Timeout) > 0 {
+		problems = append(problems, fmt.Errorf("interval and timeout are only allowed with until"))
+	}
+	if len(step.Save) > 0 {
+		problems = append(problems, validateTemplateVariables(macro, fmt.Sprintf("save '%s'", step.Save), step.Save, ids)...)
+	}
+	return problems
+}
+
+// macroStepIDs returns the ids set by a step or by the steps of its parallel group
+func macroStepIDs(step *MacroStep) []string {
+	var ids []string
+	if len(step.ID) > 0 {
+		ids = append(ids, step.ID)
+	}
+	for _, s := range step.Parallel {
+		if len(s.ID) > 0 {
+			ids = append(ids, s.ID)
+		}
+	}
+	return ids
+}
+
+// validateTemplateVariables reports template errors, variables neither declared by the macro nor allowed
+// and results of steps not among ids
+func validateTemplateVariables(macro *Macro, what string, text string, ids map[string]bool, allowed ...string) []error {
+	fields, err := templateFields(text)
+	if err != nil {
+		return []error{fmt.Errorf("%s: %w", what, err)}
+	}
+	var problems []error
+	for _, f := range fields {
+		if f[0] == macroStepsVar {
+			if len(f) > 1 && !ids[f[1]] {
+				problems = append(problems, fmt.Errorf("%s references the result of step '%s', which is not run before", what, f[1]))
+			}
+			continue
+		}
+		if !isMacroVariable(macro, f[0]) && !contains(allowed, f[0]) {
+			problems = append(problems, fmt.Errorf("%s references undefined variable '%s'", what, f[0]))
+		}
+	}
+	return problems
+}
+
+func contains(slice []string, val string) bool {
+	for _, item := range slice {
+		if item == val {
+			return true
+		}
+	}
+	return false
+}
@@ -4,30 +4,33 @@ Copyright © 2023 Dataflows
 package cmd
 
 import (
-	"crypto/tls"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 
-	"github.com/goccy/go-yaml"
+	"github.com/spf13/pflag"
 	"github.com/thedataflows/go-commons/pkg/config"
-	"github.com/thedataflows/go-commons/pkg/file"
 	"github.com/thedataflows/go-commons/pkg/log"
+	"github.com/thedataflows/opnsense-cli/pkg/api"
+	"github.com/thedataflows/opnsense-cli/pkg/catalog"
 
 	"github.com/spf13/cobra"
 )
 
-type Command struct {
-	Module     string   `yaml:"module"`
-	Controller string   `yaml:"controller"`
-	Command    string   `yaml:"command"`
-	Method     string   `yaml:"method"`
-	Parameters []string `yaml:"parameters,omitempty"`
-}
+const (
+	keyCmdRawData       = "data"
+	keyCmdRawNoValidate = "no-validate"
+	keyCmdRawQuery      = "query"
+	keyCmdRawField      = "field"
+)
 
 var (
 	cmdRawCommand = &cobra.Command{
@@ -37,138 +40,478 @@ var (
 		Aliases: []string{"r"},
 		Run:     RunRawCommand,
 	}
+
+	// rawCommandBodies holds the request body schemas of the raw subcommands, by name
+	rawCommandBodies = map[string]*catalog.Body{}
+
+	// rawCatalogCategory is the API documentation category of the raw subcommands
+	rawCatalogCategory = "raw-commands"
 )
 
 func init() {
 	rootCmd.AddCommand(cmdRawCommand)
 
-	var commandsFile string
+	var commandsFile, commandsDir string
+	// The catalogue flags are global, as macros run raw commands too
+	rootCmd.PersistentFlags().StringVar(&commandsFile, "commands-file", "raw-commands.yaml", "Raw commands file, the embedded catalogues are used when it is not accessible")
+	rootCmd.PersistentFlags().StringVar(&commandsDir, "commands-dir", "", "Directory of raw commands files named raw-commands-<version>.yaml, selected by the OPNSense version")
 	// Set persistent flags instead of local flags to be able to use them in subcommands
-	cmdRawCommand.PersistentFlags().StringVar(&commandsFile, "commands-file", "raw-commands.yaml", "Commands file")
-	// force parsing of flags
-	_ = cmdRawCommand.ParseFlags(os.Args)
+	cmdRawCommand.PersistentFlags().StringP(keyCmdRawData, "d", "", "JSON request body, '@file' to read it from a file or '-' from stdin")
+	cmdRawCommand.PersistentFlags().Bool(keyCmdRawNoValidate, false, "Send the request body without validating it against the model schema")
+	cmdRawCommand.PersistentFlags().StringArrayP(keyCmdRawQuery, "q", nil, "Query parameter as name=value. Can be specified multiple times")
+	cmdRawCommand.PersistentFlags().StringArrayP(keyCmdRawField, "f", nil, "JSON request body field as name=value, nested under the model key of model endpoints, instead of --data. Dotted names set nested fields. Can be specified multiple times")
+	_ = cmdRawCommand.RegisterFlagCompletionFunc(keyCmdRawQuery, rawInputCompletion("query"))
+	_ = cmdRawCommand.RegisterFlagCompletionFunc(keyCmdRawField, rawInputCompletion("post"))
+	// force parsing of the catalogue flags, ignoring the flags of other commands
+	catalogFlags := pflag.NewFlagSet(cmdRawCommand.Use, pflag.ContinueOnError)
+	catalogFlags.ParseErrorsWhitelist.UnknownFlags = true
+	catalogFlags.Usage = func() {}
+	catalogFlags.BoolP("help", "h", false, "")
+	catalogFlags.AddFlag(rootCmd.PersistentFlags().Lookup("commands-file"))
+	catalogFlags.AddFlag(rootCmd.PersistentFlags().Lookup("commands-dir"))
+	_ = catalogFlags.Parse(os.Args[1:])
 
 	config.ViperBindPFlagSet(cmdRawCommand, cmdRawCommand.PersistentFlags())
 
-	if !file.IsAccessible(commandsFile) {
-		log.Fatalf("Config file %s is not accessible", commandsFile)
-	}
-
-	contents, err := os.ReadFile(commandsFile)
+	var err error
+	rawCatalogs, err = loadRawCatalogs(commandsFile, catalogFlags.Changed("commands-file"), commandsDir)
 	if err != nil {
 		log.Fatal(err)
 	}
+	// until the OPNsense version is known, use the latest catalogue
+	rawCatalogSelected = rawCatalogs[len(rawCatalogs)-1]
 
-	var rawCommands []Command
-	err = yaml.UnmarshalWithOptions(contents, &rawCommands, yaml.Strict())
-	if err != nil {
-		log.Fatalf("Failed to parse file %s: %v", commandsFile, err)
-	}
-
-	apiCategory := filepath.Base(commandsFile)
-	apiCategory = apiCategory[:len(apiCategory)-len(filepath.Ext(apiCategory))]
-
-	// Add subcommands
-	for _, subcommand := range rawCommands {
-		group := &cobra.Group{
-			ID:    fmt.Sprintf("%s/%s", subcommand.Module, subcommand.Controller),
-			Title: fmt.Sprintf("Module: %s, Controller: %s", subcommand.Module, subcommand.Controller),
-		}
-		if !cmdRawCommand.ContainsGroup(group.ID) {
-			cmdRawCommand.AddGroup(group)
-		}
-
-		short := fmt.Sprintf("Method: %s", subcommand.Method)
-		if len(subcommand.Parameters) > 0 {
-			short = fmt.Sprintf("%s, Arguments: %s", short, subcommand.Parameters)
-		}
-		subCmd := &cobra.Command{
-			Use:     fmt.Sprintf("%s/%s/%s", subcommand.Module, subcommand.Controller, subcommand.Command),
-			GroupID: group.ID,
-			Short:   short,
-			Args:    cobra.MinimumNArgs(len(subcommand.Parameters)),
-			Annotations: map[string]string{
-				"method": subcommand.Method,
-			},
-			Long: fmt.Sprintf("\nhttps://docs.opnsense.org/development/api/%s/%s.html\n\n%s", apiCategory, subcommand.Module, short),
-			Run: func(cmd *cobra.Command, args []string) {
-				callingURL := fmt.Sprintf("%s/api/%s", config.ViperGetString(cmd.Root(), keyCommonOpnSenseURL), cmd.Use)
-				if len(args) > 0 {
-					callingURL = fmt.Sprintf("%s/%s", callingURL, strings.Join(args, "/"))
-				}
-				opnsenseKey := config.ViperGetString(cmd.Root(), keyCommonOpnSenseKey)
-				opnsenseSecret := config.ViperGetString(cmd.Root(), keyCommonOpnSenseSecret)
-				opnsenseSecretFile := config.ViperGetString(cmd.Root(), keyCommonOpnSenseSecretFile)
-				if (len(opnsenseKey) == 0 || len(opnsenseSecret) == 0) && len(opnsenseSecretFile) > 0 {
-					contents, err := os.ReadFile(opnsenseSecretFile)
+	if len(rawCatalogs) == 1 {
+		rawCatalogCategory = filepath.Base(rawCatalogs[0].Source)
+		rawCatalogCategory = rawCatalogCategory[:len(rawCatalogCategory)-len(filepath.Ext(rawCatalogCategory))]
+	}
+
+	// group the subcommands by module and controller, followed by a group per plugin
+	groups := map[string]*cobra.Group{}
+	for _, c := range rawCatalogs {
+		for j := range c.Commands {
+			group := rawCommandGroup(&c.Commands[j])
+			groups[group.ID] = group
+		}
+	}
+	groupIDs := make([]string, 0, len(groups))
+	for id := range groups {
+		groupIDs = append(groupIDs, id)
+	}
+	sort.Slice(groupIDs, func(i, j int) bool {
+		// plugin groups are named after the package, without the module/controller slash
+		pluginI, pluginJ := !strings.Contains(groupIDs[i], "/"), !strings.Contains(groupIDs[j], "/")
+		if pluginI != pluginJ {
+			return pluginJ
+		}
+		return groupIDs[i] < groupIDs[j]
+	})
+	for _, id := range groupIDs {
+		cmdRawCommand.AddGroup(groups[id])
+	}
+
+	// Add subcommands of all catalogues, hiding the ones missing from the selected catalogue
+	registered := map[string]bool{}
+	for i := len(rawCatalogs) - 1; i >= 0; i-- {
+		for j := range rawCatalogs[i].Commands {
+			subcommand := &rawCatalogs[i].Commands[j]
+			name := subcommand.Name()
+			if registered[name] {
+				continue
+			}
+			registered[name] = true
+
+			subCmd := &cobra.Command{
+				Use:         name,
+				GroupID:     rawCommandGroup(subcommand).ID,
+				Annotations: map[string]string{},
+				Run: func(cmd *cobra.Command, args []string) {
+					if version, ok := cmd.Annotations[annotationRawUnavailable]; ok {
+						log.Fatalf("Command %s does not exist in OPNSense %s", cmd.Use, version)
+					}
+					if _, err := rawInputValues(cmd, keyCmdRawQuery, "query"); err != nil {
+						log.Fatal(err)
+					}
+					data, err := rawCommandData(cmd)
 					if err != nil {
 						log.Fatal(err)
 					}
-					lines := strings.Split(string(contents), "\n")
-					if len(lines) < 2 {
-						log.Fatalf("Key/Secret file %s must contain key= and secret= fields", opnsenseSecretFile)
+					resp, err := executeRawCommand(cmd, cmd.Annotations["method"], args, data)
+					if err != nil {
+						log.Fatal(err)
 					}
-					for _, l := range lines {
-						if strings.HasPrefix(l, "key=") {
-							opnsenseKey = l[4:]
-						}
-						if strings.HasPrefix(l, "secret=") {
-							opnsenseSecret = l[7:]
-						}
+					printAPIResponse(resp)
+					if resp.StatusCode == http.StatusNotFound && len(cmd.Annotations["plugin"]) > 0 {
+						offerPluginInstall(cmd, cmd.Annotations["plugin"])
 					}
-				}
-				log.Debugf("Key: %s, Secret: %s", opnsenseKey, opnsenseSecret)
+				},
+			}
+			defineRawCommand(subCmd, subcommand)
+			if rawCatalogs[i] != rawCatalogSelected {
+				subCmd.Hidden = true
+				subCmd.Annotations[annotationRawUnavailable] = rawCatalogSelected.Name()
+			}
+			cmdRawCommand.AddCommand(subCmd)
+		}
+	}
+
+	// select the catalogue matching the OPNsense version before running or describing raw commands
+	cmdRawCommand.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		if err := selectRawCatalog(cmd, true); err != nil {
+			return err
+		}
+		return cmd.ValidateArgs(args)
+	}
+	helpFunc := cmdRawCommand.HelpFunc()
+	cmdRawCommand.SetHelpFunc(func(cmd *cobra.Command, args []string) {
+		if !rawCatalogResolved && len(rawCatalogs) > 1 {
+			// help is shown before cobra initializes the configuration
+			initConfig()
+			if err := selectRawCatalog(cmd, true); err != nil {
+				log.Warn(err)
+			}
+		}
+		helpFunc(cmd, args)
+	})
+}
+
+// rawCommandGroup returns the help group of a raw subcommand: its module and controller, or its plugin
+func rawCommandGroup(def *catalog.Command) *cobra.Group {
+	if len(def.Plugin) > 0 {
+		return &cobra.Group{
+			ID:    def.Plugin,
+			Title: fmt.Sprintf("Plugin: %s, install it with 'opnsense-cli raw %s %s'", def.Plugin, pluginInstallCommand, def.Plugin),
+		}
+	}
+	return &cobra.Group{
+		ID:    fmt.Sprintf("%s/%s", def.Module, def.Controller),
+		Title: fmt.Sprintf("Module: %s, Controller: %s", def.Module, def.Controller),
+	}
+}
+
+// defineRawCommand sets the help, arguments and annotations of a raw subcommand from its catalogue definition
+func defineRawCommand(subCmd *cobra.Command, def *catalog.Command) {
+	short := fmt.Sprintf("Method: %s", def.Method)
+	if len(def.Parameters) > 0 {
+		short = fmt.Sprintf("%s, Arguments: %s", short, def.Parameters)
+	}
+	long := fmt.Sprintf(
+		"\nhttps://docs.opnsense.org/development/api/%s/%s.html\nOffline: opnsense-cli help api %s\n\n%s",
+		rawCatalogCategory,
+		def.Module,
+		def.Module,
+		short,
+	)
+	if len(def.Query) > 0 {
+		long = fmt.Sprintf("%s\n\n%s", long, inputsHelp("Query parameters, set with --query:", def.Query))
+	}
+	if len(def.Post) > 0 {
+		long = fmt.Sprintf("%s\n\n%s", long, inputsHelp("Body fields, set with --field:", def.Post))
+	}
+	if def.Body != nil {
+		long = fmt.Sprintf("%s\n\n%s", long, bodyHelp(def.Body))
+	}
+	methods := []string{def.Method}
+	if len(def.Methods) > 0 {
+		methods = methods[:0]
+		lines := []string{"Accepted methods:"}
+		for _, m := range def.Methods {
+			methods = append(methods, m.Method)
+			lines = append(lines, fmt.Sprintf("  %s: %s", m.Method, strings.Join(m.Evidence, ", ")))
+		}
+		long = fmt.Sprintf("%s\n\n%s", long, strings.Join(lines, "\n"))
+	}
+	if len(def.Plugin) > 0 {
+		long = fmt.Sprintf("%s\n\nProvided by the plugin %s, which must be installed: opnsense-cli raw %s %s", long, def.Plugin, pluginInstallCommand, def.Plugin)
+	}
+	if def.MethodUncertain {
+		short = fmt.Sprintf("%s (uncertain)", short)
+		long = fmt.Sprintf("%s\n\nThe method is uncertain, the generator found no evidence in the controller code", long)
+	}
+	subCmd.Short = short
+	subCmd.Long = long
+	subCmd.Args = cobra.MinimumNArgs(len(def.Parameters))
+	subCmd.Annotations["method"] = def.Method
+	subCmd.Annotations["methods"] = strings.Join(methods, ",")
+	subCmd.Annotations["methodUncertain"] = strconv.FormatBool(def.MethodUncertain)
+	subCmd.Annotations["parameters"] = strings.Join(def.Parameters, ",")
+	subCmd.Annotations["query"] = inputNames(def.Query)
+	subCmd.Annotations["post"] = inputNames(def.Post)
+	subCmd.Annotations["plugin"] = def.Plugin
+	if def.Body != nil {
+		rawCommandBodies[subCmd.Use] = def.Body
+	} else {
+		delete(rawCommandBodies, subCmd.Use)
+	}
+}
+
+// inputsHelp describes the query parameters or body fields read by the controller
+func inputsHelp(title string, inputs []catalog.Input) string {
+	lines := []string{title}
+	for _, in := range inputs {
+		var attrs []string
+		if len(in.Filter) > 0 {
+			attrs = append(attrs, in.Filter)
+		}
+		if len(in.Default) > 0 {
+			attrs = append(attrs, fmt.Sprintf("default: %s", in.Default))
+		}
+		line := "  " + in.Name
+		if len(attrs) > 0 {
+			line = fmt.Sprintf("%s (%s)", line, strings.Join(attrs, ", "))
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func inputNames(inputs []catalog.Input) string {
+	names := make([]string, 0, len(inputs))
+	for _, in := range inputs {
+		names = append(names, in.Name)
+	}
+	return strings.Join(names, ",")
+}
+
+// rawInputCompletion completes --query or --field with the names read by the controller, stored in the annotation
+func rawInputCompletion(annotation string) func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return func(cmd *cobra.Command, _ []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(cmd.Annotations[annotation]) == 0 || strings.Contains(toComplete, "=") {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		var names []string
+		for _, name := range strings.Split(cmd.Annotations[annotation], ",") {
+			if strings.HasPrefix(name, toComplete) {
+				names = append(names, name+"=")
+			}
+		}
+		return names, cobra.ShellCompDirectiveNoSpace | cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+// rawInputValues parses the name=value pairs of a --query or --field flag, warning about names the controller does not
+// read, as listed in annotation
+func rawInputValues(cmd *cobra.Command, flag string, annotation string) (map[string]string, error) {
+	pairs, _ := cmd.Flags().GetStringArray(flag)
+	known := strings.Split(cmd.Annotations[annotation], ",")
+	values := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		name, value, ok := strings.Cut(pair, "=")
+		if !ok || len(name) == 0 {
+			return nil, fmt.Errorf("--%s '%s' must be name=value", flag, pair)
+		}
+		if len(cmd.Annotations[annotation]) > 0 && !contains(known, name) {
+			log.Warnf("%s is not known to read '%s', known: %s", cmd.Use, name, cmd.Annotations[annotation])
+		}
+		values[name] = value
+	}
+	return values, nil
+}
 
-				callOpnSenseAPI(
-					callingURL,
-					cmd.Annotations["method"],
-					opnsenseKey,
-					opnsenseSecret,
-					config.ViperGetBool(cmd.Root(), keyCommonOpnSenseURLInsecure),
-				)
-			},
+// bodyHelp describes the fields of a request body
+func bodyHelp(body *catalog.Body) string {
+	lines := []string{fmt.Sprintf("Body: {\"%s\": {...}}, model %s", body.Key, body.Model)}
+	var describe func(fields []catalog.Field, indent string)
+	describe = func(fields []catalog.Field, indent string) {
+		for _, f := range fields {
+			attrs := []string{f.Type}
+			if f.Required {
+				attrs = append(attrs, "required")
+			}
+			if f.Multiple {
+				attrs = append(attrs, "multiple")
+			}
+			if len(f.Default) > 0 {
+				attrs = append(attrs, fmt.Sprintf("default: %s", f.Default))
+			}
+			if len(f.Options) > 0 {
+				attrs = append(attrs, fmt.Sprintf("options: %s", strings.Join(f.Options, "|")))
+			}
+			if len(f.Mask) > 0 {
+				attrs = append(attrs, fmt.Sprintf("mask: %s", f.Mask))
+			}
+			lines = append(lines, fmt.Sprintf("%s%s (%s)", indent, f.Name, strings.Join(attrs, ", ")))
+			describe(f.Fields, indent+"  ")
 		}
-		cmdRawCommand.AddCommand(subCmd)
 	}
+	describe(body.Fields, "  ")
+	return strings.Join(lines, "\n")
 }
 
 func RunRawCommand(cmd *cobra.Command, _ []string) {
 	_ = cmd.Help()
 }
 
-func callOpnSenseAPI(url string, method string, key string, secret string, insecure bool) {
-	log.Infof("%s %s", method, url)
+// rawCommandURL returns the API URL of a raw subcommand called with args and the --query parameters
+func rawCommandURL(cmd *cobra.Command, args []string) string {
+	callingURL := fmt.Sprintf("%s/api/%s", config.ViperGetString(cmd.Root(), keyCommonOpnSenseURL), cmd.Use)
+	if len(args) > 0 {
+		callingURL = fmt.Sprintf("%s/%s", callingURL, strings.Join(args, "/"))
+	}
+	if pairs, _ := cmd.Flags().GetStringArray(keyCmdRawQuery); len(pairs) > 0 {
+		query := url.Values{}
+		for _, pair := range pairs {
+			name, value, _ := strings.Cut(pair, "=")
+			query.Add(name, value)
+		}
+		callingURL = fmt.Sprintf("%s?%s", callingURL, query.Encode())
+	}
+	return callingURL
+}
 
-	client := &http.Client{Transport: &http.Transport{
-		TLSClientConfig: &tls.Config{
-			InsecureSkipVerify: insecure,
-		},
-	}} // #nosec
+// rawCommandParameters returns the declared parameters of a raw subcommand
+func rawCommandParameters(cmd *cobra.Command) []string {
+	if len(cmd.Annotations["parameters"]) == 0 {
+		return nil
+	}
+	return strings.Split(cmd.Annotations["parameters"], ",")
+}
 
-	req, err := http.NewRequest(method, url, nil)
+// rawCommandData returns the request body given with --data or --field, validated against the model schema when known
+func rawCommandData(cmd *cobra.Command) ([]byte, error) {
+	body := rawCommandBodies[cmd.Use]
+	value, _ := cmd.Flags().GetString(keyCmdRawData)
+	// the names of model fields are checked by the body validation instead
+	annotation := "post"
+	if body != nil {
+		annotation = ""
+	}
+	fields, err := rawInputValues(cmd, keyCmdRawField, annotation)
+	if err != nil {
+		return nil, err
+	}
+	var data []byte
+	switch {
+	case len(value) > 0 && len(fields) > 0:
+		return nil, fmt.Errorf("--%s and --%s cannot be used together", keyCmdRawData, keyCmdRawField)
+	case len(fields) > 0:
+		var obj map[string]interface{}
+		if obj, err = rawFieldsObject(body, fields); err != nil {
+			return nil, err
+		}
+		data, err = json.Marshal(obj)
+	case len(value) == 0:
+		return nil, nil
+	case value == "-":
+		data, err = io.ReadAll(os.Stdin)
+	case strings.HasPrefix(value, "@"):
+		data, err = os.ReadFile(filepath.Clean(value[1:]))
+	default:
+		data = []byte(value)
+	}
 	if err != nil {
-		log.Fatalf("Error creating request: %s", err)
+		return nil, fmt.Errorf("failed to read request body: %w", err)
 	}
 
-	req.SetBasicAuth(key, secret)
+	if noValidate, _ := cmd.Flags().GetBool(keyCmdRawNoValidate); noValidate || body == nil {
+		return data, nil
+	}
+	// add* creates a new node, so its required fields must be set. set* only updates the given fields
+	partial := !strings.HasPrefix(cmd.Use[strings.LastIndex(cmd.Use, "/")+1:], "add")
+	problems := body.Validate(data, partial)
+	if len(problems) > 0 {
+		for _, p := range problems {
+			log.Error(p)
+		}
+		return nil, fmt.Errorf("request body does not match the model %s, use --%s to send it anyway", body.Model, keyCmdRawNoValidate)
+	}
+	return data, nil
+}
 
-	resp, err := client.Do(req)
-	if err != nil {
-		log.Fatalf("Error calling %s: %s", url, err)
+// rawFieldsObject returns the request body of the --field values. Dotted names set nested objects, e.g. 'rule.enabled',
+// and with a model body the fields are set under its key, e.g. {"rule": {"enabled": "0"}} for 'enabled=0'
+func rawFieldsObject(body *catalog.Body, fields map[string]string) (map[string]interface{}, error) {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	// parents sort before their children, so that a value set on a parent is reported as a conflict
+	sort.Strings(names)
+	obj := map[string]interface{}{}
+	for _, name := range names {
+		node := obj
+		parts := strings.Split(name, ".")
+		for i, part := range parts[:len(parts)-1] {
+			child, ok := node[part]
+			if !ok {
+				child = map[string]interface{}{}
+				node[part] = child
+			}
+			if node, ok = child.(map[string]interface{}); !ok {
+				return nil, fmt.Errorf("--%s '%s' conflicts with '%s'", keyCmdRawField, name, strings.Join(parts[:i+1], "."))
+			}
+		}
+		node[parts[len(parts)-1]] = fields[name]
+	}
+	if body != nil {
+		return map[string]interface{}{body.Key: obj}, nil
+	}
+	return obj, nil
+}
+
+// opnSenseCredentials returns the API key and secret, read from the key/secret file when not set directly
+func opnSenseCredentials(cmd *cobra.Command) (string, string, error) {
+	opnsenseKey := config.ViperGetString(cmd.Root(), keyCommonOpnSenseKey)
+	opnsenseSecret := config.ViperGetString(cmd.Root(), keyCommonOpnSenseSecret)
+	opnsenseSecretFile := config.ViperGetString(cmd.Root(), keyCommonOpnSenseSecretFile)
+	if (len(opnsenseKey) == 0 || len(opnsenseSecret) == 0) && len(opnsenseSecretFile) > 0 {
+		contents, err := os.ReadFile(opnsenseSecretFile)
+		if err != nil {
+			return "", "", err
+		}
+		lines := strings.Split(string(contents), "\n")
+		if len(lines) < 2 {
+			return "", "", fmt.Errorf("key/secret file %s must contain key= and secret= fields", opnsenseSecretFile)
+		}
+		for _, l := range lines {
+			if strings.HasPrefix(l, "key=") {
+				opnsenseKey = l[4:]
+			}
+			if strings.HasPrefix(l, "secret=") {
+				opnsenseSecret = l[7:]
+			}
+		}
 	}
-	defer resp.Body.Close()
+	log.Debugf("Key: %s, Secret: %s", opnsenseKey, opnsenseSecret)
+	return opnsenseKey, opnsenseSecret, nil
+}
 
-	body, err := io.ReadAll(resp.Body)
+// opnSenseClient returns an API client for the configured OPNsense
+func opnSenseClient(cmd *cobra.Command) (*api.Client, error) {
+	opnsenseKey, opnsenseSecret, err := opnSenseCredentials(cmd)
 	if err != nil {
-		log.Fatalf("Error reading response body: %s", err)
+		return nil, err
 	}
+	return api.NewClient(
+		config.ViperGetString(cmd.Root(), keyCommonOpnSenseURL),
+		opnsenseKey,
+		opnsenseSecret,
+		config.ViperGetBool(cmd.Root(), keyCommonOpnSenseURLInsecure),
+	), nil
+}
 
-	var data interface{}
-	err = json.Unmarshal(body, &data)
+// executeRawCommand calls the API endpoint of a raw subcommand with method, sending data as JSON body when not nil
+func executeRawCommand(cmd *cobra.Command, method string, args []string, data []byte) (*api.Response, error) {
+	client, err := opnSenseClient(cmd)
 	if err != nil {
-		log.Fatalf("Error parsing response body: %s\n%s", err, body)
+		return nil, err
+	}
+	url := rawCommandURL(cmd, args)
+	// a method chosen by the caller, e.g. a macro step, is not the uncertain one of the catalogue
+	if cmd.Annotations["methodUncertain"] == "true" && method == cmd.Annotations["method"] {
+		log.Warnf("The method of %s is uncertain, calling it with %s", cmd.Use, method)
+	}
+	log.Infof("%s %s", method, url)
+	return client.Do(context.Background(), method, url, data)
+}
+
+// printAPIResponse prints the indented JSON response to stdout, or the body when it is not JSON
+func printAPIResponse(resp *api.Response) {
+	if resp.Data == nil && len(resp.Body) > 0 && !json.Valid(resp.Body) {
+		fmt.Println(string(resp.Body))
+		return
 	}
-	prettyJSON, err := json.MarshalIndent(data, "", "    ")
+	prettyJSON, err := json.MarshalIndent(resp.Data, "", "    ")
 	if err != nil {
 		log.Fatalf("Error formatting response body: %s", err)
 	}
@@ -0,0 +1,253 @@
+/*
+Copyright © 2023 Dataflows
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/goccy/go-yaml"
+	"github.com/spf13/cobra"
+	"github.com/thedataflows/go-commons/pkg/config"
+	"github.com/thedataflows/go-commons/pkg/log"
+	"github.com/thedataflows/opnsense-cli/catalogs"
+	"github.com/thedataflows/opnsense-cli/pkg/catalog"
+)
+
+// annotationRawUnavailable marks raw subcommands missing from the selected catalogue
+const annotationRawUnavailable = "unavailable"
+
+// rawCatalog is a raw commands catalogue, for an OPNsense release or the latest one when Version is empty
+type rawCatalog struct {
+	Version string
+	// Source is the file the catalogue was read from
+	Source   string
+	Commands []catalog.Command
+
+	byName map[string]*catalog.Command
+}
+
+var (
+	// rawCatalogs are the loaded catalogues, sorted by version, the latest one last
+	rawCatalogs []*rawCatalog
+	// rawCatalogSelected is the catalogue the raw subcommands are currently defined from
+	rawCatalogSelected *rawCatalog
+	// rawCatalogResolved is true once the catalogue matching the OPNsense version was selected
+	rawCatalogResolved bool
+
+	rawCatalogFileName = regexp.MustCompile(`^raw-commands(?:-(.+))?\.ya?ml$`)
+)
+
+// Name returns the version of the catalogue or 'latest'
+func (c *rawCatalog) Name() string {
+	if len(c.Version) == 0 {
+		return "latest"
+	}
+	return c.Version
+}
+
+// Command returns the definition of the raw command named module/controller/command, nil when missing
+func (c *rawCatalog) Command(name string) *catalog.Command {
+	if c.byName == nil {
+		c.byName = make(map[string]*catalog.Command, len(c.Commands))
+		for i := range c.Commands {
+			cmd := &c.Commands[i]
+			c.byName[cmd.Name()] = cmd
+		}
+	}
+	return c.byName[name]
+}
+
+// parseRawCatalog parses catalogue contents, the version being read from file names like raw-commands-24.7.yaml
+func parseRawCatalog(source string, contents []byte) (*rawCatalog, error) {
+	c := &rawCatalog{Source: source}
+	if m := rawCatalogFileName.FindStringSubmatch(path.Base(source)); m != nil {
+		c.Version = m[1]
+	}
+	if err := yaml.UnmarshalWithOptions(contents, &c.Commands, yaml.Strict()); err != nil {
+		return nil, fmt.Errorf("failed to parse file %s: %w", source, err)
+	}
+	return c, nil
+}
+
+// readRawCatalogs parses the raw-commands*.yaml catalogues of a directory
+func readRawCatalogs(fsys fs.FS, dir string) ([]*rawCatalog, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+	result := []*rawCatalog{}
+	for _, e := range entries {
+		if e.IsDir() || !rawCatalogFileName.MatchString(e.Name()) {
+			continue
+		}
+		contents, err := fs.ReadFile(fsys, e.Name())
+		if err != nil {
+			return nil, err
+		}
+		c, err := parseRawCatalog(path.Join(dir, e.Name()), contents)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, c)
+	}
+	if len(result) == 0 {
+		return nil, fmt.Errorf("no raw-commands*.yaml catalogue found in %s", dir)
+	}
+	sortRawCatalogs(result)
+	return result, nil
+}
+
+// loadRawCatalogs reads the catalogues of commandsDir when set, else commandsFile when accessible,
+// else the embedded ones. A commandsFile set explicitly must be accessible
+func loadRawCatalogs(commandsFile string, commandsFileSet bool, commandsDir string) ([]*rawCatalog, error) {
+	if len(commandsDir) > 0 {
+		return readRawCatalogs(os.DirFS(commandsDir), commandsDir)
+	}
+	if _, err := os.Stat(commandsFile); err == nil || commandsFileSet {
+		contents, err := os.ReadFile(commandsFile)
+		if err != nil {
+			return nil, fmt.Errorf("commands file %s is not accessible: %w", commandsFile, err)
+		}
+		c, err := parseRawCatalog(commandsFile, contents)
+		if err != nil {
+			return nil, err
+		}
+		return []*rawCatalog{c}, nil
+	}
+	return readRawCatalogs(catalogs.FS, "embedded")
+}
+
+// sortRawCatalogs sorts by version, the latest catalogue, without version, last
+func sortRawCatalogs(list []*rawCatalog) {
+	sort.SliceStable(list, func(i, j int) bool {
+		switch {
+		case len(list[i].Version) == 0:
+			return false
+		case len(list[j].Version) == 0:
+			return true
+		}
+		return catalog.CompareVersions(list[i].Version, list[j].Version) < 0
+	})
+}
+
+// matchRawCatalog returns the catalogue of the greatest release not newer than version. Versions older
+// than every release use the latest catalogue when loaded, else the oldest release
+func matchRawCatalog(list []*rawCatalog, version string) *rawCatalog {
+	var match *rawCatalog
+	for _, c := range list {
+		if len(c.Version) > 0 && catalog.CompareVersions(c.Version, version) <= 0 {
+			match = c
+		}
+	}
+	if match != nil {
+		return match
+	}
+	if latest := list[len(list)-1]; len(latest.Version) == 0 {
+		return latest
+	}
+	return list[0]
+}
+
+// opnSenseVersion queries the product version, e.g. 24.7.1, from core/firmware/status, else core/firmware/info, with
+// the method of the loaded catalogue, POST when missing from it
+func opnSenseVersion(cmd *cobra.Command) (string, error) {
+	client, err := opnSenseClient(cmd)
+	if err != nil {
+		return "", err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var lastErr error
+	for _, command := range []string{"core/firmware/status", "core/firmware/info"} {
+		method := http.MethodPost
+		if rawCmd := findRawCommand(command); rawCmd != nil && len(rawCmd.Annotations["method"]) > 0 {
+			method = rawCmd.Annotations["method"]
+		}
+		resp, err := client.Call(ctx, method, command, nil, nil)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		var status struct {
+			ProductVersion string `json:"product_version"`
+			Product        struct {
+				ProductVersion string `json:"product_version"`
+			} `json:"product"`
+		}
+		if err := resp.Decode(&status); err != nil {
+			lastErr = err
+			continue
+		}
+		if len(status.ProductVersion) > 0 {
+			return status.ProductVersion, nil
+		}
+		if len(status.Product.ProductVersion) > 0 {
+			return status.Product.ProductVersion, nil
+		}
+		lastErr = fmt.Errorf("%s returned no product_version", command)
+	}
+	return "", lastErr
+}
+
+// selectRawCatalog defines the raw subcommands from the catalogue matching the OPNsense version, given with
+// --opnsense-version or, when query is true, queried from OPNsense. It does nothing when a single catalogue is loaded
+func selectRawCatalog(cmd *cobra.Command, query bool) error {
+	if rawCatalogResolved || len(rawCatalogs) < 2 {
+		return nil
+	}
+	version := config.ViperGetString(cmd.Root(), keyCommonOpnSenseVersion)
+	if len(version) == 0 {
+		if !query {
+			return nil
+		}
+		var err error
+		if version, err = opnSenseVersion(cmd); err != nil {
+			return fmt.Errorf("failed to query the OPNSense version, set it with --%s: %w", keyCommonOpnSenseVersion, err)
+		}
+	}
+	c := matchRawCatalog(rawCatalogs, version)
+	log.Infof("OPNSense %s, using the raw commands catalogue %s", version, c.Source)
+	useRawCatalog(c, version)
+	rawCatalogResolved = true
+	return nil
+}
+
+// useRawCatalog defines the raw subcommands from the catalogue, hiding the ones missing in the OPNsense version
+func useRawCatalog(c *rawCatalog, version string) {
+	rawCatalogSelected = c
+	for _, subCmd := range cmdRawCommand.Commands() {
+		if _, ok := subCmd.Annotations["method"]; !ok {
+			// e.g. help and completion
+			continue
+		}
+		def := c.Command(subCmd.Use)
+		if def == nil {
+			subCmd.Hidden = true
+			subCmd.Annotations[annotationRawUnavailable] = version
+			continue
+		}
+		subCmd.Hidden = false
+		delete(subCmd.Annotations, annotationRawUnavailable)
+		defineRawCommand(subCmd, def)
+	}
+}
+
+// rawCommandNotFound explains why findRawCommand found no raw subcommand with the given name
+func rawCommandNotFound(name string) error {
+	for _, cmd := range cmdRawCommand.Commands() {
+		if version, ok := cmd.Annotations[annotationRawUnavailable]; ok && cmd.Name() == name {
+			return fmt.Errorf("command '%s' does not exist in OPNSense %s", name, version)
+		}
+	}
+	return fmt.Errorf("unknown command '%s'", name)
+}
@@ -0,0 +1,47 @@
+/*
+Copyright © 2023 Dataflows
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/spf13/cobra"
+	"github.com/thedataflows/go-commons/pkg/config"
+	"github.com/thedataflows/go-commons/pkg/log"
+)
+
+const (
+	// pluginInstallCommand installs a package in the background, given as argument
+	pluginInstallCommand = "core/firmware/install"
+	// pluginInstallStatusCommand reports the progress of the installation
+	pluginInstallStatusCommand = "core/firmware/upgradestatus"
+)
+
+// offerPluginInstall explains that the plugin providing a command is missing and, on a terminal, offers to install it.
+// --yes approves the command asked for, not installing a package: it only prints the install command then
+func offerPluginInstall(cmd *cobra.Command, plugin string) {
+	log.Errorf("%s is provided by the plugin %s, which seems not to be installed", cmd.Use, plugin)
+	if !isTerminal() || config.ViperGetBool(rootCmd, keyCommonYes) {
+		log.Infof("Install it with: opnsense-cli raw %s %s", pluginInstallCommand, plugin)
+		return
+	}
+	if err := confirmAction(fmt.Sprintf("Install the plugin %s with %s?", plugin, pluginInstallCommand)); err != nil {
+		log.Info(err)
+		return
+	}
+
+	client, err := opnSenseClient(cmd)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Infof("%s %s", http.MethodPost, client.CommandURL(pluginInstallCommand, []string{plugin}))
+	resp, err := client.Call(context.Background(), http.MethodPost, pluginInstallCommand, []string{plugin}, nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	printAPIResponse(resp)
+	log.Infof("The plugin is installed in the background, follow the progress with: opnsense-cli raw %s", pluginInstallStatusCommand)
+}
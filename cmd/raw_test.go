@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/thedataflows/opnsense-cli/pkg/catalog"
+)
+
+func TestRawFieldsObject(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    *catalog.Body
+		fields  map[string]string
+		want    map[string]interface{}
+		wantErr string
+	}{
+		{
+			name:   "top level",
+			fields: map[string]string{"destination": "10.0.0.0/24", "gateway": "192.168.1.1"},
+			want:   map[string]interface{}{"destination": "10.0.0.0/24", "gateway": "192.168.1.1"},
+		},
+		{
+			name:   "model key",
+			body:   &catalog.Body{Key: "rule"},
+			fields: map[string]string{"enabled": "0", "source.net": "any"},
+			want: map[string]interface{}{"rule": map[string]interface{}{
+				"enabled": "0",
+				"source":  map[string]interface{}{"net": "any"},
+			}},
+		},
+		{
+			name:    "conflict",
+			fields:  map[string]string{"a": "1", "a.b": "2"},
+			wantErr: "--field 'a.b' conflicts with 'a'",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := rawFieldsObject(tt.body, tt.fields)
+			if len(tt.wantErr) > 0 {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("rawFieldsObject() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("rawFieldsObject() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
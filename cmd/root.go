@@ -20,6 +20,8 @@ const (
 	keyCommonOpnSenseSecret      = "opnsense-secret"
 	keyCommonOpnSenseURLInsecure = "opnsense-url-insecure"
 	keyCommonOpnSenseSecretFile  = "opnsense-secret-file"
+	keyCommonYes                 = "yes"
+	keyCommonOpnSenseVersion     = "opnsense-version"
 )
 
 var (
@@ -63,6 +65,8 @@ func init() {
 	rootCmd.PersistentFlags().String(keyCommonOpnSenseSecret, "", "OPNSense Secret")
 	rootCmd.PersistentFlags().String(keyCommonOpnSenseSecretFile, "", "Optional OPNSense Key and Secret File (downloaded from gui)")
 	rootCmd.PersistentFlags().Bool(keyCommonOpnSenseURLInsecure, false, "OPNSense URL is Insecure")
+	rootCmd.PersistentFlags().BoolP(keyCommonYes, "y", false, "Assume yes when asked to confirm changes, required to run them without a terminal")
+	rootCmd.PersistentFlags().String(keyCommonOpnSenseVersion, "", "OPNSense version selecting the raw commands catalogue, e.g. 24.7.1. Queried from OPNSense when empty and several catalogues are loaded, e.g. with --commands-dir")
 
 	config.ViperBindPFlagSet(rootCmd, rootCmd.PersistentFlags())
 }
@@ -0,0 +1,182 @@
+/*
+Copyright © 2023 Dataflows
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"github.com/thedataflows/go-commons/pkg/log"
+	"github.com/thedataflows/opnsense-cli/pkg/catalog"
+	"github.com/thedataflows/opnsense-cli/pkg/schema"
+)
+
+const (
+	schemaKindMacro   = "macro"
+	schemaKindCatalog = "catalog"
+	schemaKindConfig  = "config"
+)
+
+var (
+	cmdSchema = &cobra.Command{
+		Use:   "schema <kind>",
+		Short: "Print the JSON Schema of a file format",
+		Long: fmt.Sprintf(`Print the JSON Schema of macro files (%s), raw commands catalogues (%s) or config files (%s).
+Editors with a YAML language server use them for completion and validation, e.g. with the modeline:
+  # yaml-language-server: $schema=./macro.schema.json`, schemaKindMacro, schemaKindCatalog, schemaKindConfig),
+		Example: `  opnsense-cli schema macro > macro.schema.json
+  opnsense-cli schema config > config.schema.json`,
+		Args:      cobra.ExactValidArgs(1),
+		ValidArgs: []string{schemaKindMacro, schemaKindCatalog, schemaKindConfig},
+		Run:       RunSchema,
+	}
+
+	// schemaDescriptions document the types of the schemas, by type name and by type and field name
+	schemaDescriptions = map[string]string{
+		"Macro":                   "Macro, a sequence of raw commands and macros called as <file name>/<name>",
+		"Macro.name":              "Name of the macro, unique in the file",
+		"Macro.tags":              "Tags filtering the macros in 'opnsense-cli macro list'",
+		"Macro.params":            "Documented variables, set with --var",
+		"Macro.vars":              "Variables, available to the steps as '{{ .name }}'",
+		"Macro.commands":          "Steps run in order",
+		"Macro.schedule":          "Cron expression, e.g. '0 3 * * *' or '@every 1h', run by 'opnsense-cli daemon'",
+		"MacroParam":              "Macro variable that can be set with --var",
+		"MacroParam.required":     "The variable must be set",
+		"MacroParam.default":      "Value of the variable when not set",
+		"MacroStep":               "Step calling a raw command or another macro",
+		"MacroStep.id":            "Names the step result, available to the next steps as '{{ .steps.<id> }}'",
+		"MacroStep.command":       "Raw command, e.g. firewall/alias/searchItem",
+		"MacroStep.method":        "HTTP method the raw command is called with instead of its default one, among the methods it accepts",
+		"MacroStep.args":          "Arguments of the raw command",
+		"MacroStep.macro":         "Macro called, e.g. builtin/firmware-update",
+		"MacroStep.vars":          "Variables of the called macro",
+		"MacroStep.parallel":      "Steps run at once",
+		"MacroStep.limit":         "Maximum number of parallel steps running at once, all when 0",
+		"MacroStep.assert":        "Templates that must render to 'true' for the step to pass, seeing '{{ .response }}' and '{{ .status_code }}'",
+		"MacroStep.confirm":       "Ask for confirmation before running the step. When unset, POST steps are confirmed",
+		"MacroStep.save":          "Template of the file receiving the response body, instead of printing it",
+		"MacroStep.until":         "Template repeating the step every interval until it renders to 'true' or timeout passes",
+		"MacroStep.interval":      "Duration between the repetitions of until, 5s by default",
+		"MacroStep.timeout":       "Maximum duration of the repetitions of until, 10m by default",
+		"Command":                 "Raw command, an API endpoint called as module/controller/command",
+		"Command.method":          "HTTP method of the command",
+		"Command.parameters":      "Path parameters, '$name=default' when optional",
+		"Command.methods":         "Accepted HTTP methods, with the controller code showing them",
+		"Command.methodUncertain": "The method was assumed by the generator, lacking evidence",
+		"Command.query":           "Query parameters read by the controller",
+		"Command.post":            "Top level body fields read by the controller",
+		"Command.body":            "Request body schema of add*/set* commands, from the model XML",
+		"Command.plugin":          "Package of the plugin providing the command, e.g. os-haproxy",
+		"Body":                    "Request body, an object holding the model node at path under key",
+		"Field":                   "Field of an OPNsense model, as defined in its XML",
+		"Field.fields":            "Children of containers and ArrayFields",
+		"Input":                   "Query parameter or posted body field read by name in the controller code",
+		"Input.filter":            "Sanitizing filter applied by the controller, e.g. 'string' or 'int'",
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(cmdSchema)
+}
+
+func RunSchema(_ *cobra.Command, args []string) {
+	var s *schema.Schema
+	switch args[0] {
+	case schemaKindMacro:
+		s = macroSchema()
+	case schemaKindCatalog:
+		s = schema.Reflect(reflect.TypeOf([]catalog.Command{}), "opnsense-cli raw commands catalogue", schemaDescriptions)
+	case schemaKindConfig:
+		s = configSchema()
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(s); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// macroSchema returns the schema of the macro files
+func macroSchema() *schema.Schema {
+	s := schema.Reflect(reflect.TypeOf([]Macro{}), "opnsense-cli macro file", schemaDescriptions)
+	// steps are also written as the name of a raw command, see MacroStep.UnmarshalYAML
+	s.Defs["MacroStepObject"] = s.Defs["MacroStep"]
+	s.Defs["MacroStep"] = &schema.Schema{
+		Description: s.Defs["MacroStepObject"].Description,
+		OneOf: []*schema.Schema{
+			{Type: "string", Description: "Raw command without arguments, e.g. core/firmware/status"},
+			{Ref: "#/$defs/MacroStepObject"},
+		},
+	}
+	return s
+}
+
+// configSchema returns the schema of the config file, holding the flags bound to config keys: the global flags at the
+// top level and the flags of a command under its name, e.g. 'raw'
+func configSchema() *schema.Schema {
+	s := &schema.Schema{
+		Schema:               schema.Draft,
+		Title:                "opnsense-cli config file",
+		Type:                 "object",
+		Properties:           map[string]*schema.Schema{},
+		AdditionalProperties: false,
+	}
+	keys := viper.AllKeys()
+	sort.Strings(keys)
+	for _, key := range keys {
+		path := strings.Split(key, ".")
+		flag := configKeyFlag(path)
+		// keys read from the config files are not flags, and the config files are not set in themselves
+		if flag == nil || flag.Name == "config" {
+			continue
+		}
+		node := s
+		for _, name := range path[:len(path)-1] {
+			child, ok := node.Properties[name]
+			if !ok {
+				child = &schema.Schema{
+					Description:          fmt.Sprintf("Flags of 'opnsense-cli %s'", name),
+					Type:                 "object",
+					Properties:           map[string]*schema.Schema{},
+					AdditionalProperties: false,
+				}
+				node.Properties[name] = child
+			}
+			node = child
+		}
+		node.Properties[flag.Name] = schema.FromFlag(flag)
+	}
+	return s
+}
+
+// configKeyFlag returns the flag bound to the config key path, made of the command names and the flag name
+func configKeyFlag(path []string) *pflag.Flag {
+	cmd := rootCmd
+	for _, name := range path[:len(path)-1] {
+		var next *cobra.Command
+		for _, c := range cmd.Commands() {
+			if c.Use == name {
+				next = c
+				break
+			}
+		}
+		if next == nil {
+			return nil
+		}
+		cmd = next
+	}
+	name := path[len(path)-1]
+	if flag := cmd.PersistentFlags().Lookup(name); flag != nil {
+		return flag
+	}
+	return cmd.Flags().Lookup(name)
+}
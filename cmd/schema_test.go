@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMacroSchema(t *testing.T) {
+	s := macroSchema()
+	if want := []string{"name", "commands"}; !reflect.DeepEqual(s.Defs["Macro"].Required, want) {
+		t.Errorf("Macro required = %q, want %q", s.Defs["Macro"].Required, want)
+	}
+	// steps are either the name of a raw command or an object
+	step := s.Defs["MacroStep"]
+	if len(step.OneOf) != 2 || step.OneOf[0].Type != "string" || step.OneOf[1].Ref != "#/$defs/MacroStepObject" {
+		t.Errorf("MacroStep oneOf = %+v, want a string or a MacroStepObject", step.OneOf)
+	}
+	object := s.Defs["MacroStepObject"]
+	if object == nil || len(object.Required) > 0 || object.AdditionalProperties != false {
+		t.Fatalf("MacroStepObject = %+v, want an object without required fields nor unknown ones", object)
+	}
+	if object.Properties["parallel"].Items.Ref != "#/$defs/MacroStep" {
+		t.Errorf("MacroStepObject parallel items = %+v, want MacroStep", object.Properties["parallel"].Items)
+	}
+}
+
+func TestConfigSchema(t *testing.T) {
+	s := configSchema()
+	tests := []struct {
+		key  []string
+		want string
+	}{
+		{key: []string{"opnsense-url"}, want: "string"},
+		{key: []string{"yes"}, want: "boolean"},
+		// the flags of a command are nested under its name
+		{key: []string{"raw", "field"}, want: "array"},
+		{key: []string{"daemon", "var"}, want: "object"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key[len(tt.key)-1], func(t *testing.T) {
+			node := s
+			for _, name := range tt.key {
+				if node.AdditionalProperties != false {
+					t.Fatalf("%+v allows unknown keys", node)
+				}
+				var ok bool
+				if node, ok = node.Properties[name]; !ok {
+					t.Fatalf("key %q is missing", name)
+				}
+			}
+			if node.Type != tt.want {
+				t.Errorf("type = %q, want %q", node.Type, tt.want)
+			}
+		})
+	}
+	// config files are not set in themselves
+	if _, ok := s.Properties["config"]; ok {
+		t.Error("key 'config' must not be in the schema")
+	}
+}
@@ -0,0 +1,142 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Client calls the OPNsense API, authenticating with an API key and secret
+type Client struct {
+	// URL is the OPNsense base URL, e.g. https://opnsense.local
+	URL        string
+	Key        string
+	Secret     string
+	HTTPClient *http.Client
+}
+
+// NewClient returns a client for the OPNsense at url. insecure skips the TLS certificate verification
+func NewClient(url string, key string, secret string, insecure bool) *Client {
+	return &Client{
+		URL:    strings.TrimRight(url, "/"),
+		Key:    key,
+		Secret: secret,
+		HTTPClient: &http.Client{Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{
+				InsecureSkipVerify: insecure,
+			},
+		}}, // #nosec
+	}
+}
+
+// Response is the outcome of an API call
+type Response struct {
+	Method     string
+	URL        string
+	StatusCode int
+	Body       []byte
+	// Data is the parsed JSON body, nil for other content types like configuration backups
+	Data interface{}
+}
+
+// StatusError is returned by Call for 4xx and 5xx responses
+type StatusError struct {
+	Response *Response
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s %s: HTTP %d: %s", e.Response.Method, e.Response.URL, e.Response.StatusCode, bytes.TrimSpace(e.Response.Body))
+}
+
+// Decode unmarshals the JSON body into v
+func (r *Response) Decode(v interface{}) error {
+	if err := json.Unmarshal(r.Body, v); err != nil {
+		return fmt.Errorf("error parsing response body: %w", err)
+	}
+	return nil
+}
+
+// CommandURL returns the URL of an API command, e.g. 'firewall/alias/searchItem', called with args
+func (c *Client) CommandURL(command string, args []string) string {
+	callingURL := fmt.Sprintf("%s/api/%s", c.URL, command)
+	if len(args) > 0 {
+		callingURL = fmt.Sprintf("%s/%s", callingURL, strings.Join(args, "/"))
+	}
+	return callingURL
+}
+
+// Do calls url, sending data as JSON body when not nil. Responses with an error status are returned without error
+func (c *Client) Do(ctx context.Context, method string, url string, data []byte) (*Response, error) {
+	var reqBody io.Reader
+	if data != nil {
+		reqBody = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+	if data != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.SetBasicAuth(c.Key, c.Secret)
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error calling %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading response body: %w", err)
+	}
+
+	result := &Response{
+		Method:     method,
+		URL:        url,
+		StatusCode: resp.StatusCode,
+		Body:       body,
+	}
+	if !strings.Contains(resp.Header.Get("Content-Type"), "json") && !json.Valid(body) {
+		// e.g. configuration backups, returned as is
+		return result, nil
+	}
+	err = json.Unmarshal(body, &result.Data)
+	if err != nil {
+		return result, fmt.Errorf("error parsing response body: %w\n%s", err, body)
+	}
+	return result, nil
+}
+
+// Call calls an API command with args, sending body as JSON when not nil.
+// Trailing empty args are dropped, so optional parameters can be left unset.
+// Responses with an error status are returned along with a *StatusError
+func (c *Client) Call(ctx context.Context, method string, command string, args []string, body interface{}) (*Response, error) {
+	for len(args) > 0 && len(args[len(args)-1]) == 0 {
+		args = args[:len(args)-1]
+	}
+	var data []byte
+	if body != nil {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			return nil, fmt.Errorf("error encoding request body: %w", err)
+		}
+	}
+	resp, err := c.Do(ctx, method, c.CommandURL(command, args), data)
+	if err != nil {
+		return resp, err
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		return resp, &StatusError{Response: resp}
+	}
+	return resp, nil
+}
@@ -0,0 +1,79 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Field is the value of a model field. OPNsense receives values as text, while get* responses
+// describe option fields as {"key": {"value": "label", "selected": 1}}, decoded to the comma separated selected keys
+type Field string
+
+// UnmarshalJSON accepts text, numbers, booleans and option lists
+func (f *Field) UnmarshalJSON(data []byte) error {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	switch v := value.(type) {
+	case nil:
+		*f = ""
+	case string:
+		*f = Field(v)
+	case bool:
+		*f = "0"
+		if v {
+			*f = "1"
+		}
+	case float64:
+		*f = Field(fmt.Sprintf("%v", v))
+	case map[string]interface{}:
+		selected := []string{}
+		for key, option := range v {
+			if o, ok := option.(map[string]interface{}); ok && isSelected(o["selected"]) {
+				selected = append(selected, key)
+			}
+		}
+		sort.Strings(selected)
+		*f = Field(strings.Join(selected, ","))
+	case []interface{}:
+		// empty option lists are sent as arrays
+		if len(v) > 0 {
+			return fmt.Errorf("unexpected field value %s", data)
+		}
+		*f = ""
+	default:
+		return fmt.Errorf("unexpected field value %s", data)
+	}
+	return nil
+}
+
+func isSelected(v interface{}) bool {
+	switch s := v.(type) {
+	case bool:
+		return s
+	case float64:
+		return s != 0
+	case string:
+		return s == "1" || s == "true"
+	}
+	return false
+}
+
+// SearchResult is the response of search* commands
+type SearchResult struct {
+	Rows     []map[string]interface{} `json:"rows"`
+	RowCount int                      `json:"rowCount"`
+	Total    int                      `json:"total"`
+	Current  int                      `json:"current"`
+}
+
+// SearchOptions are the parameters of search* commands
+type SearchOptions struct {
+	Current      int               `json:"current,omitempty"`
+	RowCount     int               `json:"rowCount,omitempty"`
+	SearchPhrase string            `json:"searchPhrase,omitempty"`
+	Sort         map[string]string `json:"sort,omitempty"`
+}
@@ -0,0 +1,165 @@
+package apidoc
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/thedataflows/opnsense-cli/pkg/catalog"
+)
+
+const (
+	FormatMarkdown = "markdown"
+	FormatMan      = "man"
+)
+
+var (
+	// Formats are the output formats of the API reference
+	Formats = []string{FormatMarkdown, FormatMan}
+
+	//go:embed templates/*.gotmpl
+	templates embed.FS
+
+	tmpl = template.Must(template.New("apidoc").Funcs(template.FuncMap{
+		"cell":   cell,
+		"fields": fields,
+		"join":   strings.Join,
+		"roff":   roff,
+		"upper":  strings.ToUpper,
+	}).ParseFS(templates, "templates/*.gotmpl"))
+)
+
+// Module is the API reference of an OPNsense module
+type Module struct {
+	Name        string
+	Controllers []*Controller
+}
+
+// Controller holds the commands of a controller, sorted by name
+type Controller struct {
+	Name     string
+	Plugin   string
+	Commands []*catalog.Command
+}
+
+// FieldRow is a body field with its dotted path from the body key, e.g. alias.type
+type FieldRow struct {
+	Path string
+	*catalog.Field
+}
+
+// Modules groups the commands by module and controller, sorted by name
+func Modules(commands []catalog.Command) []*Module {
+	byName := map[string]*Module{}
+	controllers := map[string]*Controller{}
+	for i := range commands {
+		c := &commands[i]
+		m, ok := byName[c.Module]
+		if !ok {
+			m = &Module{Name: c.Module}
+			byName[c.Module] = m
+		}
+		key := c.Module + "/" + c.Controller
+		controller, ok := controllers[key]
+		if !ok {
+			controller = &Controller{Name: c.Controller, Plugin: c.Plugin}
+			controllers[key] = controller
+			m.Controllers = append(m.Controllers, controller)
+		}
+		controller.Commands = append(controller.Commands, c)
+	}
+
+	modules := make([]*Module, 0, len(byName))
+	for _, m := range byName {
+		sort.Slice(m.Controllers, func(i, j int) bool { return m.Controllers[i].Name < m.Controllers[j].Name })
+		for _, c := range m.Controllers {
+			sort.SliceStable(c.Commands, func(i, j int) bool { return c.Commands[i].Command < c.Commands[j].Command })
+		}
+		modules = append(modules, m)
+	}
+	sort.Slice(modules, func(i, j int) bool { return modules[i].Name < modules[j].Name })
+	return modules
+}
+
+// Plugins returns the sorted plugin packages providing the controllers of the module
+func (m *Module) Plugins() []string {
+	var plugins []string
+	seen := map[string]bool{}
+	for _, c := range m.Controllers {
+		if len(c.Plugin) > 0 && !seen[c.Plugin] {
+			seen[c.Plugin] = true
+			plugins = append(plugins, c.Plugin)
+		}
+	}
+	sort.Strings(plugins)
+	return plugins
+}
+
+// Render writes the reference of the module in the format, one of Formats
+func (m *Module) Render(format string) ([]byte, error) {
+	var name string
+	switch format {
+	case FormatMarkdown:
+		name = "module.md.gotmpl"
+	case FormatMan:
+		name = "module.7.gotmpl"
+	default:
+		return nil, fmt.Errorf("unknown format '%s', one of: %s", format, strings.Join(Formats, ", "))
+	}
+	var out bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&out, name, m); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// FileName returns the name of the reference file of the module in the format, e.g. firewall.md or opnsense-cli-api-firewall.7
+func (m *Module) FileName(format string) string {
+	if format == FormatMan {
+		return fmt.Sprintf("opnsense-cli-api-%s.7", m.Name)
+	}
+	return m.Name + ".md"
+}
+
+// fields flattens the fields of a body, array items being keyed by uuid, shown as '*'
+func fields(body *catalog.Body) []FieldRow {
+	var rows []FieldRow
+	var walk func(prefix string, fields []catalog.Field)
+	walk = func(prefix string, fields []catalog.Field) {
+		for i := range fields {
+			f := &fields[i]
+			path := prefix + "." + f.Name
+			switch {
+			case f.Type == catalog.ContainerType:
+				walk(path, f.Fields)
+			case f.IsArray():
+				rows = append(rows, FieldRow{Path: path, Field: f})
+				walk(path+".*", f.Fields)
+			default:
+				rows = append(rows, FieldRow{Path: path, Field: f})
+			}
+		}
+	}
+	walk(body.Key, body.Fields)
+	return rows
+}
+
+// cell escapes text for a Markdown table cell
+func cell(text string) string {
+	return strings.ReplaceAll(text, "|", `\|`)
+}
+
+// roff escapes text for a man page: backslashes, dashes and control characters at the start of lines
+func roff(text string) string {
+	text = strings.NewReplacer(`\`, `\e`, "-", `\-`).Replace(text)
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(line, ".") || strings.HasPrefix(line, "'") {
+			lines[i] = `\&` + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,34 @@
+package apidoc
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestModulePlugins(t *testing.T) {
+	tests := []struct {
+		name        string
+		controllers []*Controller
+		want        []string
+	}{
+		{name: "core", controllers: []*Controller{{Name: "service"}}},
+		{
+			name: "plugins",
+			controllers: []*Controller{
+				{Name: "a", Plugin: "os-b"},
+				{Name: "b"},
+				{Name: "c", Plugin: "os-a"},
+				{Name: "d", Plugin: "os-b"},
+			},
+			want: []string{"os-a", "os-b"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &Module{Name: "module", Controllers: tt.controllers}
+			if got := m.Plugins(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Plugins() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,29 @@
+package catalog
+
+import "fmt"
+
+// Command is a raw command of the catalogue, an API endpoint called as module/controller/command
+type Command struct {
+	Module     string   `yaml:"module" json:"module"`
+	Controller string   `yaml:"controller" json:"controller"`
+	Command    string   `yaml:"command" json:"command"`
+	Method     string   `yaml:"method" json:"method"`
+	Parameters []string `yaml:"parameters,omitempty" json:"parameters,omitempty"`
+	// Methods are the accepted HTTP methods, with the controller code showing them
+	Methods []MethodEvidence `yaml:"methods,omitempty" json:"methods,omitempty"`
+	// MethodUncertain is true when Method was assumed by the generator, lacking evidence
+	MethodUncertain bool `yaml:"methodUncertain,omitempty" json:"methodUncertain,omitempty"`
+	// Query are the query parameters read by the controller
+	Query []Input `yaml:"query,omitempty" json:"query,omitempty"`
+	// Post are the top level body fields read by the controller
+	Post []Input `yaml:"post,omitempty" json:"post,omitempty"`
+	// Body is the request body schema of add*/set* commands, from the model XML
+	Body *Body `yaml:"body,omitempty" json:"body,omitempty"`
+	// Plugin is the package of the plugin providing the command, e.g. os-haproxy, empty for the core
+	Plugin string `yaml:"plugin,omitempty" json:"plugin,omitempty"`
+}
+
+// Name returns the name of the command, e.g. firewall/alias/searchItem
+func (c *Command) Name() string {
+	return fmt.Sprintf("%s/%s/%s", c.Module, c.Controller, c.Command)
+}
@@ -0,0 +1,197 @@
+package catalog
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// ContainerType is the type of model nodes without a type attribute, grouping other fields
+	ContainerType = "container"
+)
+
+// Field describes a field of an OPNsense model, as defined in its XML
+type Field struct {
+	Name     string   `yaml:"name" json:"name"`
+	Type     string   `yaml:"type" json:"type"`
+	Required bool     `yaml:"required,omitempty" json:"required,omitempty"`
+	Default  string   `yaml:"default,omitempty" json:"default,omitempty"`
+	Multiple bool     `yaml:"multiple,omitempty" json:"multiple,omitempty"`
+	Options  []string `yaml:"options,omitempty" json:"options,omitempty"`
+	Mask     string   `yaml:"mask,omitempty" json:"mask,omitempty"`
+	// Fields are the children of containers and ArrayFields
+	Fields []Field `yaml:"fields,omitempty" json:"fields,omitempty"`
+}
+
+// IsArray returns true for ArrayFields, and types derived from them, holding items keyed by uuid
+func (f *Field) IsArray() bool {
+	return f.Type != ContainerType && len(f.Fields) > 0
+}
+
+// Body is the request body of an add*/set* endpoint: an object holding the model node at Path under Key
+type Body struct {
+	Key    string  `yaml:"key" json:"key"`
+	Model  string  `yaml:"model" json:"model"`
+	Path   string  `yaml:"path,omitempty" json:"path,omitempty"`
+	Fields []Field `yaml:"fields" json:"fields"`
+}
+
+// Model is a parsed OPNsense model XML
+type Model struct {
+	Mount       string
+	Description string
+	Fields      []Field
+}
+
+type xmlNode struct {
+	XMLName xml.Name
+	Attrs   []xml.Attr `xml:",any,attr"`
+	Content string     `xml:",chardata"`
+	Nodes   []xmlNode  `xml:",any"`
+}
+
+func (n *xmlNode) attr(name string) string {
+	for _, a := range n.Attrs {
+		if strings.EqualFold(a.Name.Local, name) {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+func (n *xmlNode) child(name string) *xmlNode {
+	for i := range n.Nodes {
+		if strings.EqualFold(n.Nodes[i].XMLName.Local, name) {
+			return &n.Nodes[i]
+		}
+	}
+	return nil
+}
+
+func (n *xmlNode) text(name string) string {
+	if c := n.child(name); c != nil {
+		return strings.TrimSpace(c.Content)
+	}
+	return ""
+}
+
+// ParseModelFile parses an OPNsense model XML file
+func ParseModelFile(fileName string) (*Model, error) {
+	data, err := os.ReadFile(filepath.Clean(fileName))
+	if err != nil {
+		return nil, err
+	}
+	model, err := ParseModel(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", fileName, err)
+	}
+	return model, nil
+}
+
+// ParseModel parses the contents of an OPNsense model XML
+func ParseModel(data []byte) (*Model, error) {
+	var root xmlNode
+	if err := xml.Unmarshal(data, &root); err != nil {
+		return nil, err
+	}
+	items := root.child("items")
+	if items == nil {
+		return nil, fmt.Errorf("model has no items")
+	}
+	return &Model{
+		Mount:       root.text("mount"),
+		Description: root.text("description"),
+		Fields:      parseFields(items),
+	}, nil
+}
+
+// parseFields returns the fields defined by the children of a container node
+func parseFields(node *xmlNode) []Field {
+	fields := []Field{}
+	for i := range node.Nodes {
+		child := &node.Nodes[i]
+		fieldType := fieldTypeName(child.attr("type"))
+		if len(fieldType) == 0 {
+			fields = append(fields, Field{
+				Name:   child.XMLName.Local,
+				Type:   ContainerType,
+				Fields: parseFields(child),
+			})
+			continue
+		}
+		field := Field{
+			Name:     child.XMLName.Local,
+			Type:     fieldType,
+			Required: isYes(child.text("Required")),
+			Default:  child.text("Default"),
+			Multiple: isYes(child.text("Multiple")),
+			Mask:     child.text("mask"),
+		}
+		// ArrayFields, and types derived from them, have typed children
+		for _, c := range child.Nodes {
+			if len(c.attr("type")) > 0 {
+				field.Fields = parseFields(child)
+				break
+			}
+		}
+		if options := child.child("OptionValues"); options != nil {
+			for _, o := range options.Nodes {
+				value := o.attr("value")
+				if len(value) == 0 {
+					value = o.XMLName.Local
+				}
+				field.Options = append(field.Options, value)
+			}
+		}
+		fields = append(fields, field)
+	}
+	return fields
+}
+
+// fieldTypeName strips the namespace from a field type, e.g. '..\..\Base\FieldTypes\TextField' or '.\AliasField'
+func fieldTypeName(t string) string {
+	t = strings.TrimSpace(t)
+	if i := strings.LastIndexAny(t, `\/`); i >= 0 {
+		t = t[i+1:]
+	}
+	return strings.TrimLeft(t, ".")
+}
+
+func isYes(value string) bool {
+	switch strings.ToUpper(value) {
+	case "Y", "YES", "TRUE", "1":
+		return true
+	}
+	return false
+}
+
+// Lookup returns the fields of the node at path, dot separated, e.g. 'aliases.alias'. An empty path returns fields
+func Lookup(fields []Field, path string) ([]Field, bool) {
+	if len(path) == 0 {
+		return fields, true
+	}
+	name, rest, _ := strings.Cut(path, ".")
+	for _, f := range fields {
+		if f.Name == name && len(f.Fields) > 0 {
+			return Lookup(f.Fields, rest)
+		}
+	}
+	return nil, false
+}
+
+// MethodEvidence is an HTTP method accepted by an endpoint, with the code showing it
+type MethodEvidence struct {
+	Method   string   `yaml:"method" json:"method"`
+	Evidence []string `yaml:"evidence" json:"evidence"`
+}
+
+// Input is a query parameter or posted body field read by name in the controller code
+type Input struct {
+	Name string `yaml:"name" json:"name"`
+	// Filter is the sanitizing filter applied by the controller, e.g. 'string' or 'int'
+	Filter  string `yaml:"filter,omitempty" json:"filter,omitempty"`
+	Default string `yaml:"default,omitempty" json:"default,omitempty"`
+}
@@ -0,0 +1,129 @@
+package catalog
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseModel(t *testing.T) {
+	tests := []struct {
+		name    string
+		xml     string
+		want    *Model
+		wantErr bool
+	}{
+		{
+			name: "fields",
+			xml: `<model>
+    <mount>//OPNsense/Firewall/Alias</mount>
+    <description>Firewall aliases</description>
+    <items>
+        <enabled type="BooleanField">
+            <Default>1</Default>
+            <Required>Y</Required>
+        </enabled>
+        <name type="..\..\Base\FieldTypes\TextField">
+            <Required>Y</Required>
+            <mask>/^[a-z0-9_]{1,32}$/i</mask>
+        </name>
+        <type type="OptionField">
+            <Required>Y</Required>
+            <Multiple>N</Multiple>
+            <OptionValues>
+                <host>Host(s)</host>
+                <network>Network(s)</network>
+                <opt1 value="url">URL (IPs)</opt1>
+            </OptionValues>
+        </type>
+        <content type=".\AliasContentField"/>
+    </items>
+</model>`,
+			want: &Model{
+				Mount:       "//OPNsense/Firewall/Alias",
+				Description: "Firewall aliases",
+				Fields: []Field{
+					{Name: "enabled", Type: "BooleanField", Required: true, Default: "1"},
+					{Name: "name", Type: "TextField", Required: true, Mask: "/^[a-z0-9_]{1,32}$/i"},
+					{Name: "type", Type: "OptionField", Required: true, Options: []string{"host", "network", "url"}},
+					{Name: "content", Type: "AliasContentField"},
+				},
+			},
+		},
+		{
+			name: "containers and arrays",
+			xml: `<model>
+    <mount>//OPNsense/Firewall/Alias</mount>
+    <items>
+        <geoip>
+            <url type="UrlField"/>
+        </geoip>
+        <aliases>
+            <alias type="ArrayField">
+                <proto type="OptionField">
+                    <Multiple>Y</Multiple>
+                    <OptionValues>
+                        <IPv4>IPv4</IPv4>
+                    </OptionValues>
+                </proto>
+            </alias>
+        </aliases>
+    </items>
+</model>`,
+			want: &Model{
+				Mount: "//OPNsense/Firewall/Alias",
+				Fields: []Field{
+					{Name: "geoip", Type: ContainerType, Fields: []Field{
+						{Name: "url", Type: "UrlField"},
+					}},
+					{Name: "aliases", Type: ContainerType, Fields: []Field{
+						{Name: "alias", Type: "ArrayField", Fields: []Field{
+							{Name: "proto", Type: "OptionField", Multiple: true, Options: []string{"IPv4"}},
+						}},
+					}},
+				},
+			},
+		},
+		{name: "no items", xml: `<model><mount>//OPNsense/Empty</mount></model>`, wantErr: true},
+		{name: "invalid XML", xml: `<model>`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseModel([]byte(tt.xml))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseModel() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseModel() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLookup(t *testing.T) {
+	fields := []Field{
+		{Name: "general", Type: ContainerType, Fields: []Field{{Name: "enabled", Type: "BooleanField"}}},
+		{Name: "aliases", Type: ContainerType, Fields: []Field{
+			{Name: "alias", Type: "ArrayField", Fields: []Field{{Name: "name", Type: "TextField"}}},
+		}},
+	}
+	tests := []struct {
+		path   string
+		want   []Field
+		wantOk bool
+	}{
+		{path: "", want: fields, wantOk: true},
+		{path: "general", want: fields[0].Fields, wantOk: true},
+		{path: "aliases.alias", want: fields[1].Fields[0].Fields, wantOk: true},
+		{path: "aliases.missing"},
+		// scalar fields have no children
+		{path: "general.enabled"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			got, ok := Lookup(fields, tt.path)
+			if ok != tt.wantOk || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Lookup(%q) = %+v, %v, want %+v, %v", tt.path, got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
@@ -0,0 +1,221 @@
+package catalog
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// FieldError is a validation problem of a request body field
+type FieldError struct {
+	// Path is the dot separated path of the field in the body, e.g. 'alias.type'
+	Path    string
+	Message string
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Path, e.Message)
+}
+
+// Validate checks a JSON request body against the schema. Unless partial, as for set* updates,
+// required fields without default must be present
+func (b *Body) Validate(data []byte, partial bool) []error {
+	var body interface{}
+	if err := json.Unmarshal(data, &body); err != nil {
+		return []error{fmt.Errorf("body is not valid JSON: %w", err)}
+	}
+	obj, ok := body.(map[string]interface{})
+	if !ok {
+		return []error{fmt.Errorf("body must be a JSON object")}
+	}
+
+	var problems []error
+	for _, key := range sortedKeys(obj) {
+		if key != b.Key {
+			problems = append(problems, &FieldError{Path: key, Message: fmt.Sprintf("unknown field, the body must hold '%s'", b.Key)})
+		}
+	}
+	value, ok := obj[b.Key]
+	if !ok {
+		return append(problems, &FieldError{Path: b.Key, Message: "missing"})
+	}
+	return append(problems, validateNode(b.Fields, value, b.Key, partial)...)
+}
+
+// validateNode checks an object holding the given fields
+func validateNode(fields []Field, value interface{}, path string, partial bool) []error {
+	obj, ok := value.(map[string]interface{})
+	if !ok {
+		return []error{&FieldError{Path: path, Message: "must be an object"}}
+	}
+
+	var problems []error
+	byName := make(map[string]*Field, len(fields))
+	for i := range fields {
+		byName[fields[i].Name] = &fields[i]
+	}
+	for _, name := range sortedKeys(obj) {
+		field, ok := byName[name]
+		if !ok {
+			problems = append(problems, &FieldError{Path: path + "." + name, Message: "unknown field"})
+			continue
+		}
+		problems = append(problems, validateField(field, obj[name], path+"."+name, partial)...)
+	}
+	if !partial {
+		for _, f := range fields {
+			if _, ok := obj[f.Name]; !ok && f.Required && len(f.Default) == 0 {
+				problems = append(problems, &FieldError{Path: path + "." + f.Name, Message: "required field is missing"})
+			}
+		}
+	}
+	return problems
+}
+
+// validateField checks the value of a single field
+func validateField(field *Field, value interface{}, path string, partial bool) []error {
+	switch {
+	case field.Type == ContainerType:
+		return validateNode(field.Fields, value, path, partial)
+	case field.IsArray():
+		items, ok := value.(map[string]interface{})
+		if !ok {
+			return []error{&FieldError{Path: path, Message: "must be an object of items keyed by uuid"}}
+		}
+		var problems []error
+		for _, uuid := range sortedKeys(items) {
+			problems = append(problems, validateNode(field.Fields, items[uuid], path+"."+uuid, partial)...)
+		}
+		return problems
+	}
+
+	text, err := scalarText(value)
+	if err != nil {
+		return []error{&FieldError{Path: path, Message: err.Error()}}
+	}
+	if len(text) == 0 {
+		if field.Required && !partial {
+			return []error{&FieldError{Path: path, Message: "required field is empty"}}
+		}
+		return nil
+	}
+
+	var problems []error
+	switch field.Type {
+	case "BooleanField":
+		if text != "0" && text != "1" {
+			problems = append(problems, &FieldError{Path: path, Message: fmt.Sprintf("'%s' is not a boolean, use 0 or 1", text)})
+		}
+	case "OptionField":
+		if len(field.Options) == 0 {
+			break
+		}
+		values := []string{text}
+		if field.Multiple {
+			values = strings.Split(text, ",")
+		}
+		for _, v := range values {
+			if !contains(field.Options, v) {
+				problems = append(problems, &FieldError{Path: path, Message: fmt.Sprintf("'%s' is not one of %s", v, strings.Join(field.Options, ", "))})
+			}
+		}
+	case "NetworkField":
+		for _, v := range strings.Split(text, ",") {
+			if !isNetwork(strings.TrimSpace(v)) {
+				problems = append(problems, &FieldError{Path: path, Message: fmt.Sprintf("'%s' is not an address or network", v)})
+			}
+		}
+	}
+	if re := maskRegexp(field.Mask); re != nil && !re.MatchString(text) {
+		problems = append(problems, &FieldError{Path: path, Message: fmt.Sprintf("'%s' does not match %s", text, field.Mask)})
+	}
+	return problems
+}
+
+// scalarText returns the value as OPNsense receives it: strings, numbers and booleans as text,
+// lists as comma separated values
+func scalarText(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case bool:
+		if v {
+			return "1", nil
+		}
+		return "0", nil
+	case float64:
+		return fmt.Sprintf("%v", v), nil
+	case []interface{}:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			text, err := scalarText(item)
+			if err != nil {
+				return "", err
+			}
+			items = append(items, text)
+		}
+		return strings.Join(items, ","), nil
+	}
+	return "", fmt.Errorf("must be a value, not an object")
+}
+
+// isNetwork returns true for addresses, networks in CIDR notation and 'any'
+func isNetwork(value string) bool {
+	if value == "any" || net.ParseIP(value) != nil {
+		return true
+	}
+	_, _, err := net.ParseCIDR(value)
+	return err == nil
+}
+
+// MaskPattern converts a PCRE mask like '/^[a-z]+$/i' to a regular expression.
+// It returns an empty string for masks go cannot compile
+func MaskPattern(mask string) string {
+	if len(mask) < 2 || mask[0] != '/' {
+		return ""
+	}
+	end := strings.LastIndex(mask, "/")
+	if end == 0 {
+		return ""
+	}
+	expr := mask[1:end]
+	if flags := mask[end+1:]; strings.Contains(flags, "i") {
+		expr = "(?i)" + expr
+	}
+	if _, err := regexp.Compile(expr); err != nil {
+		return ""
+	}
+	return expr
+}
+
+// maskRegexp compiles a mask, returning nil when it cannot be checked
+func maskRegexp(mask string) *regexp.Regexp {
+	expr := MaskPattern(mask)
+	if len(expr) == 0 {
+		return nil
+	}
+	return regexp.MustCompile(expr)
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func contains(slice []string, val string) bool {
+	for _, item := range slice {
+		if item == val {
+			return true
+		}
+	}
+	return false
+}
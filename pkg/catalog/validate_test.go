@@ -0,0 +1,104 @@
+package catalog
+
+import (
+	"reflect"
+	"testing"
+)
+
+// aliasBody is a trimmed alias model body, with every field kind Validate checks
+var aliasBody = &Body{
+	Key:   "alias",
+	Model: "OPNsense/Firewall/Alias",
+	Path:  "aliases.alias",
+	Fields: []Field{
+		{Name: "enabled", Type: "BooleanField", Required: true, Default: "1"},
+		{Name: "name", Type: "TextField", Required: true, Mask: "/^[a-z0-9_]{1,32}$/i"},
+		{Name: "type", Type: "OptionField", Required: true, Options: []string{"host", "network", "port"}},
+		{Name: "proto", Type: "OptionField", Multiple: true, Options: []string{"IPv4", "IPv6"}},
+		{Name: "content", Type: "NetworkField"},
+		{Name: "counters", Type: ContainerType, Fields: []Field{
+			{Name: "enabled", Type: "BooleanField"},
+		}},
+		{Name: "items", Type: "ArrayField", Fields: []Field{
+			{Name: "address", Type: "NetworkField", Required: true},
+		}},
+	},
+}
+
+func TestBodyValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		partial bool
+		want    []string
+	}{
+		{name: "valid", data: `{"alias":{"name":"Servers","type":"host","proto":"IPv4,IPv6","content":"10.0.0.0/8,any"}}`},
+		{name: "scalars as text", data: `{"alias":{"enabled":true,"name":"a1","type":"port","counters":{"enabled":false}}}`},
+		{name: "invalid JSON", data: `{`, want: []string{"body is not valid JSON: unexpected end of JSON input"}},
+		{name: "not an object", data: `[]`, want: []string{"body must be a JSON object"}},
+		{name: "missing key", data: `{"rule":{}}`, want: []string{
+			"rule: unknown field, the body must hold 'alias'",
+			"alias: missing",
+		}},
+		{name: "missing required fields", data: `{"alias":{}}`, want: []string{
+			"alias.name: required field is missing",
+			"alias.type: required field is missing",
+		}},
+		{name: "partial update", data: `{"alias":{"content":"192.168.1.1"}}`, partial: true},
+		{name: "empty required field", data: `{"alias":{"name":"","type":"host"}}`, want: []string{"alias.name: required field is empty"}},
+		{name: "empty required field in partial update", data: `{"alias":{"name":""}}`, partial: true},
+		{name: "invalid values", data: `{"alias":{"enabled":"yes","name":"a b","type":"url","proto":"IPv4,IPv5","content":"10.0.0.300"}}`, want: []string{
+			"alias.content: '10.0.0.300' is not an address or network",
+			"alias.enabled: 'yes' is not a boolean, use 0 or 1",
+			"alias.name: 'a b' does not match /^[a-z0-9_]{1,32}$/i",
+			"alias.proto: 'IPv5' is not one of IPv4, IPv6",
+			"alias.type: 'url' is not one of host, network, port",
+		}},
+		{name: "unknown field", data: `{"alias":{"name":"a","type":"host","color":"red"}}`, want: []string{"alias.color: unknown field"}},
+		{name: "object as value", data: `{"alias":{"name":{"a":"b"},"type":"host"}}`, want: []string{"alias.name: must be a value, not an object"}},
+		{name: "container not an object", data: `{"alias":{"name":"a","type":"host","counters":"1"}}`, want: []string{"alias.counters: must be an object"}},
+		{name: "array items", data: `{"alias":{"name":"a","type":"host","items":{"u1":{"address":"10.0.0.1"},"u2":{}}}}`, want: []string{
+			"alias.items.u2.address: required field is missing",
+		}},
+		{name: "array not an object", data: `{"alias":{"name":"a","type":"host","items":[]}}`, want: []string{
+			"alias.items: must be an object of items keyed by uuid",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, err := range aliasBody.Validate([]byte(tt.data), tt.partial) {
+				got = append(got, err.Error())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Validate(%s, %v) = %q, want %q", tt.data, tt.partial, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMaskPattern(t *testing.T) {
+	tests := []struct {
+		mask string
+		want string
+	}{
+		{mask: "/^[a-z]+$/", want: "^[a-z]+$"},
+		{mask: "/^[a-z]+$/i", want: "(?i)^[a-z]+$"},
+		{mask: "/^[a-z]+$/u", want: "^[a-z]+$"},
+		{mask: "/^a/b$/", want: "^a/b$"},
+		// not delimited by slashes
+		{mask: "^[a-z]+$", want: ""},
+		{mask: "/", want: ""},
+		{mask: "/^[a-z]+$", want: ""},
+		{mask: "", want: ""},
+		// look-arounds are not supported by go
+		{mask: "/^(?!-)[a-z]+$/", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mask, func(t *testing.T) {
+			if got := MaskPattern(tt.mask); got != tt.want {
+				t.Errorf("MaskPattern(%q) = %q, want %q", tt.mask, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package catalog
+
+import (
+	"strconv"
+	"strings"
+)
+
+// CompareVersions compares OPNsense versions like 24.7, 24.7.1 or 24.7.1_3, returning -1, 0 or 1.
+// Numeric parts are compared as numbers, missing parts are lower, e.g. 24.7 < 24.7.1 < 24.7.1_3
+func CompareVersions(a string, b string) int {
+	partsA := versionParts(a)
+	partsB := versionParts(b)
+	for i := 0; i < len(partsA) || i < len(partsB); i++ {
+		switch {
+		case i >= len(partsA):
+			return -1
+		case i >= len(partsB):
+			return 1
+		}
+		if c := comparePart(partsA[i], partsB[i]); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// versionParts splits a version on dots and the underscore of the package revision
+func versionParts(version string) []string {
+	version = strings.TrimPrefix(strings.TrimSpace(version), "v")
+	if len(version) == 0 {
+		return nil
+	}
+	return strings.FieldsFunc(version, func(r rune) bool { return r == '.' || r == '_' || r == '-' })
+}
+
+func comparePart(a string, b string) int {
+	numA, errA := strconv.Atoi(a)
+	numB, errB := strconv.Atoi(b)
+	switch {
+	case errA == nil && errB == nil:
+		return compareInts(numA, numB)
+	case errA == nil:
+		// numbers sort after pre-release names like 'r1'
+		return 1
+	case errB == nil:
+		return -1
+	}
+	return strings.Compare(a, b)
+}
+
+func compareInts(a int, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,34 @@
+package catalog
+
+import "testing"
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a    string
+		b    string
+		want int
+	}{
+		{a: "24.7", b: "24.7", want: 0},
+		{a: "v24.7", b: "24.7", want: 0},
+		{a: " 24.7 ", b: "24.7", want: 0},
+		{a: "24.1", b: "24.7", want: -1},
+		{a: "24.7", b: "23.7", want: 1},
+		// numeric parts are compared as numbers
+		{a: "24.7.10", b: "24.7.9", want: 1},
+		// missing parts are lower
+		{a: "24.7", b: "24.7.1", want: -1},
+		{a: "24.7.1_3", b: "24.7.1", want: 1},
+		{a: "24.7.1_3", b: "24.7.1_12", want: -1},
+		{a: "24.7.r1", b: "24.7.1", want: -1},
+		{a: "24.7-beta", b: "24.7-alpha", want: 1},
+		{a: "", b: "24.7", want: -1},
+		{a: "", b: "", want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.a+"_"+tt.b, func(t *testing.T) {
+			if got := CompareVersions(tt.a, tt.b); got != tt.want {
+				t.Errorf("CompareVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package generator
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/thedataflows/opnsense-cli/pkg/apidoc"
+	"github.com/thedataflows/opnsense-cli/pkg/catalog"
+)
+
+// catalogCommands converts the endpoints to the raw commands of the catalogue, skipping the abstract controllers
+func catalogCommands(endpoints []Endpoint) []catalog.Command {
+	commands := make([]catalog.Command, 0, len(endpoints))
+	for _, e := range endpoints {
+		if e.IsAbstract {
+			continue
+		}
+		commands = append(commands, catalog.Command{
+			Module:          e.Module,
+			Controller:      e.Controller,
+			Command:         e.Command,
+			Method:          e.Method,
+			Parameters:      e.Parameters,
+			Methods:         e.Methods,
+			MethodUncertain: e.MethodUncertain,
+			Query:           e.Query,
+			Post:            e.Post,
+			Body:            e.Body,
+			Plugin:          e.Plugin,
+		})
+	}
+	return commands
+}
+
+// writeAPIDoc writes the API reference of every module in the format, one of apidoc.Formats, to outputDir
+func writeAPIDoc(endpoints []Endpoint, format string, outputDir string) error {
+	if err := os.MkdirAll(outputDir, 0o750); err != nil {
+		return err
+	}
+	for _, m := range apidoc.Modules(catalogCommands(endpoints)) {
+		out, err := m.Render(format)
+		if err != nil {
+			return fmt.Errorf("error rendering module '%s': %w", m.Name, err)
+		}
+		if err := os.WriteFile(filepath.Join(outputDir, m.FileName(format)), out, 0o600); err != nil {
+			return err
+		}
+	}
+	return nil
+}
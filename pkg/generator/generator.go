@@ -0,0 +1,336 @@
+package generator
+
+import (
+	"embed"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/goccy/go-yaml"
+	"github.com/thedataflows/go-commons/pkg/log"
+	"github.com/thedataflows/opnsense-cli/pkg/apidoc"
+)
+
+const (
+	excludeControllers = "Core/Api/FirmwareController.php"
+
+	FormatCatalog = "catalog"
+	FormatOpenAPI = "openapi"
+	FormatSDK     = "sdk"
+	// FormatMarkdown and FormatMan write the API reference, one file per module
+	FormatMarkdown = apidoc.FormatMarkdown
+	FormatMan      = apidoc.FormatMan
+
+	catalogTemplate = "templates/collect_api_endpoints.gotmpl"
+)
+
+var (
+	// Formats are the output formats of Generate
+	Formats = []string{FormatCatalog, FormatOpenAPI, FormatSDK, FormatMarkdown, FormatMan}
+
+	// DefaultRepos are the OPNsense repositories cloned when no source is given
+	DefaultRepos = []string{"core", "plugins"}
+
+	//go:embed templates/*.gotmpl
+	templates embed.FS
+)
+
+// Options are the settings of Generate
+type Options struct {
+	// Sources are directories or tarballs, e.g. core-24.7.tar.gz, read instead of cloning Repos
+	Sources []string
+	// Repos are the names of the OPNsense repositories on GitHub to clone
+	Repos []string
+	// Ref is the branch, tag or commit of the cloned repositories, their default branch when empty
+	Ref string
+	// CloneDir is the directory the repositories are cloned to, the temporary directory when empty
+	CloneDir string
+	// Offline only reads Sources, never cloning the repositories
+	Offline bool
+	// Format is one of Formats
+	Format string
+	// Output is the file written, or the directory for FormatSDK, FormatMarkdown and FormatMan
+	Output string
+	// Template is the template of the catalogue, when empty the one named after Output with the '.gotmpl'
+	// extension when it exists, else the embedded one
+	Template string
+}
+
+type Controller struct {
+	Type       string
+	Filename   string
+	IsAbstract bool
+	BaseClass  string
+	Endpoints  []Endpoint
+	Uses       []map[string]string
+}
+
+type TemplateData struct {
+	Title          string
+	TitleUnderline string
+	Controllers    []Controller
+}
+
+// Generate collects the API endpoints of the sources and writes them in the requested format
+func Generate(opts *Options) error {
+	switch opts.Format {
+	case FormatCatalog, FormatOpenAPI, FormatSDK, FormatMarkdown, FormatMan:
+	default:
+		return fmt.Errorf("unknown format '%s', one of: %s", opts.Format, strings.Join(Formats, ", "))
+	}
+
+	tmpDir, err := os.MkdirTemp("", "opnsense-sources-")
+	if err != nil {
+		return fmt.Errorf("error creating temporary directory: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	sources, err := opts.openSources(tmpDir)
+	if err != nil {
+		return err
+	}
+	for _, source := range sources {
+		log.Infof("Source %s", source)
+	}
+
+	controllers, err := Collect(sources)
+	if err != nil {
+		return err
+	}
+	endpoints := []Endpoint{}
+	for _, controller := range controllers {
+		endpoints = append(endpoints, controller...)
+	}
+
+	switch opts.Format {
+	case FormatOpenAPI:
+		log.Infof("Output file: %s", opts.Output)
+		if err := writeOpenAPI(buildOpenAPI(endpoints, sources), opts.Output); err != nil {
+			return fmt.Errorf("error writing OpenAPI document: %w", err)
+		}
+		return nil
+	case FormatSDK:
+		log.Infof("Output directory: %s", opts.Output)
+		if err := writeSDK(endpoints, sources, opts.Output); err != nil {
+			return fmt.Errorf("error writing SDK: %w", err)
+		}
+		return nil
+	case FormatMarkdown, FormatMan:
+		log.Infof("Output directory: %s", opts.Output)
+		if err := writeAPIDoc(endpoints, opts.Format, opts.Output); err != nil {
+			return fmt.Errorf("error writing API reference: %w", err)
+		}
+		return nil
+	}
+
+	templateFile := opts.Template
+	if len(templateFile) == 0 {
+		if _, err := os.Stat(opts.Output + ".gotmpl"); err == nil {
+			templateFile = opts.Output + ".gotmpl"
+		}
+	}
+	log.Infof("Output file: %s", opts.Output)
+	fOut, err := os.Create(filepath.Clean(opts.Output))
+	if err != nil {
+		return fmt.Errorf("error creating file: %w", err)
+	}
+	defer fOut.Close()
+	return WriteCatalog(fOut, controllers, sources, templateFile)
+}
+
+// openSources returns the Sources, or the cloned Repos when there are none
+func (opts *Options) openSources(tmpDir string) ([]*Source, error) {
+	if len(opts.Ref) > 0 && len(opts.Sources) > 0 {
+		return nil, fmt.Errorf("--ref applies to the cloned repositories, check out the ref in the --source directories instead")
+	}
+	sources := []*Source{}
+	for _, origin := range opts.Sources {
+		source, err := openSource(origin, tmpDir)
+		if err != nil {
+			return nil, fmt.Errorf("error opening source: %w", err)
+		}
+		sources = append(sources, source)
+	}
+	if len(sources) > 0 {
+		return sources, nil
+	}
+
+	if opts.Offline {
+		return nil, fmt.Errorf("no --source given, required when offline")
+	}
+	cloneDir := opts.CloneDir
+	if len(cloneDir) == 0 {
+		cloneDir = os.TempDir()
+	}
+	repos := opts.Repos
+	if len(repos) == 0 {
+		repos = DefaultRepos
+	}
+	for _, repo := range repos {
+		sourceDir := filepath.Join(cloneDir, repo)
+		repoURL := fmt.Sprintf("https://github.com/opnsense/%s.git", repo)
+		if err := cloneGitRepo(repoURL, sourceDir, opts.Ref); err != nil {
+			return nil, err
+		}
+		source := &Source{Name: repo, Dir: sourceDir, Origin: repoURL, Ref: opts.Ref}
+		if err := source.describeGit(); err != nil {
+			return nil, fmt.Errorf("error reading source revision: %w", err)
+		}
+		sources = append(sources, source)
+	}
+	return sources, nil
+}
+
+// Collect parses the controllers of the sources and returns the endpoints of each, sorted by module and controller.
+// Commands are sorted too, so the output is reproducible
+func Collect(sources []*Source) ([][]Endpoint, error) {
+	// parse the controllers and base classes of all sources, as plugins inherit from core classes
+	parsed := []*phpController{}
+	classes := map[string]*phpController{}
+	plugins := map[string]string{}
+	for _, source := range sources {
+		err := filepath.Walk(source.Dir, func(path string, info os.FileInfo, err error) error {
+			path = filepath.ToSlash(path)
+			if err != nil {
+				return err
+			}
+			lowerPath := strings.ToLower(path)
+			if info.IsDir() || !(strings.HasSuffix(lowerPath, "controller.php") || strings.HasSuffix(lowerPath, "controllerbase.php")) || !strings.Contains(lowerPath, "mvc/app/controllers") || strings.Contains(lowerPath, excludeControllers) {
+				return nil
+			}
+			c, err := parseAPIPHP(path)
+			if err != nil {
+				return err
+			}
+			if c != nil {
+				if c.Plugin, err = pluginPackage(filepath.Dir(path), filepath.ToSlash(filepath.Clean(source.Dir)), plugins); err != nil {
+					return err
+				}
+				parsed = append(parsed, c)
+				classes[c.Class] = c
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error walking path: %w", err)
+		}
+	}
+
+	controllers := [][]Endpoint{}
+	for _, c := range parsed {
+		if len(c.Module) == 0 {
+			continue
+		}
+		if payload := c.endpoints(classes); len(payload) > 0 {
+			controllers = append(controllers, payload)
+		}
+	}
+	sort.SliceStable(controllers, func(i, j int) bool {
+		if controllers[i][0].Module != controllers[j][0].Module {
+			return controllers[i][0].Module < controllers[j][0].Module
+		}
+		return controllers[i][0].Controller < controllers[j][0].Controller
+	})
+	return controllers, nil
+}
+
+// pluginMakefileName matches the plugin name set in the Makefile of the plugins, e.g. 'PLUGIN_NAME= haproxy'
+var pluginMakefileName = regexp.MustCompile(`(?m)^PLUGIN_NAME\s*\??=\s*(\S+)`)
+
+// pluginPackage returns the package of the plugin holding dir, e.g. os-haproxy, from the Makefile found in dir or its
+// parents up to root. Sources without plugin Makefile, like the core, return an empty name. Results are cached by directory
+func pluginPackage(dir string, root string, cache map[string]string) (string, error) {
+	if name, ok := cache[dir]; ok {
+		return name, nil
+	}
+	name := ""
+	contents, err := os.ReadFile(filepath.Join(dir, "Makefile"))
+	switch {
+	case err == nil && pluginMakefileName.Match(contents):
+		name = "os-" + string(pluginMakefileName.FindSubmatch(contents)[1])
+	case err != nil && !os.IsNotExist(err):
+		return "", err
+	case dir != root && filepath.Dir(dir) != dir:
+		if name, err = pluginPackage(filepath.Dir(dir), root, cache); err != nil {
+			return "", err
+		}
+	}
+	cache[dir] = name
+	return name, nil
+}
+
+// WriteCatalog writes the raw commands catalogue of the controllers, executing templateFile for every module,
+// or the embedded template when empty
+func WriteCatalog(w io.Writer, controllers [][]Endpoint, sources []*Source, templateFile string) error {
+	funcs := template.FuncMap{"toYAML": toYAML, "indent": indent}
+	var (
+		tmpl *template.Template
+		err  error
+	)
+	if len(templateFile) > 0 {
+		log.Infof("Using template: %s", templateFile)
+		tmpl, err = template.New(filepath.Base(templateFile)).Funcs(funcs).ParseFiles(templateFile)
+	} else {
+		tmpl, err = template.New(filepath.Base(catalogTemplate)).Funcs(funcs).ParseFS(templates, catalogTemplate)
+	}
+	if err != nil {
+		return fmt.Errorf("error parsing template: %w", err)
+	}
+
+	if _, err := fmt.Fprint(w, sourcesHeader(sources), "# Raw commands:"); err != nil {
+		return err
+	}
+	for start := 0; start < len(controllers); {
+		moduleName := controllers[start][0].Module
+		templateData := TemplateData{
+			Title:          strings.ToTitle(moduleName),
+			TitleUnderline: strings.Repeat("~", len(moduleName)),
+			Controllers:    []Controller{},
+		}
+		for ; start < len(controllers) && controllers[start][0].Module == moduleName; start++ {
+			controller := controllers[start]
+			payload := Controller{
+				Type:       controller[0].Type,
+				Filename:   controller[0].Filename,
+				IsAbstract: controller[0].IsAbstract,
+				BaseClass:  controller[0].BaseClass,
+				Endpoints:  controller,
+				Uses:       []map[string]string{},
+			}
+			if controller[0].ModelFilename != "" {
+				payload.Uses = append(payload.Uses, map[string]string{
+					"Type": "model",
+					"Name": filepath.Base(controller[0].ModelFilename),
+				})
+			}
+			templateData.Controllers = append(templateData.Controllers, payload)
+		}
+		if err := tmpl.Execute(w, templateData); err != nil {
+			return fmt.Errorf("error executing template: %w", err)
+		}
+	}
+	return nil
+}
+
+// toYAML is a template function marshalling v to YAML
+func toYAML(v interface{}) (string, error) {
+	out, err := yaml.MarshalWithOptions(v, yaml.Indent(2), yaml.IndentSequence(true))
+	return strings.TrimRight(string(out), "\n"), err
+}
+
+// indent is a template function prefixing every line of s with spaces
+func indent(spaces int, s string) string {
+	prefix := strings.Repeat(" ", spaces)
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		if len(line) > 0 {
+			lines[i] = prefix + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,238 @@
+package generator
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/thedataflows/opnsense-cli/pkg/catalog"
+)
+
+var update = flag.Bool("update", false, "update the golden files in testdata/golden")
+
+// fixtureSources returns the sources of the fixture trees, with a fixed revision
+func fixtureSources(names ...string) []*Source {
+	sources := make([]*Source, 0, len(names))
+	for _, name := range names {
+		sources = append(sources, &Source{
+			Name:   name,
+			Dir:    filepath.Join("testdata", name),
+			Tag:    "24.7",
+			Commit: "0123456789abcdef0123456789abcdef01234567",
+		})
+	}
+	return sources
+}
+
+// assertGolden compares got to the golden file, writing it instead with -update
+func assertGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	golden := filepath.Join("testdata", "golden", name)
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(golden), 0o750); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(golden, got, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("%v, run 'go test ./pkg/generator -update' to create it", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("output differs from %s, run 'go test ./pkg/generator -update' and review the diff", golden)
+	}
+}
+
+func TestWriteCatalog(t *testing.T) {
+	tests := []struct {
+		golden  string
+		sources []string
+	}{
+		// plugins inherit the actions of the core base classes
+		{golden: "raw-commands.yaml", sources: []string{"core", "plugins"}},
+		// without the core base classes, the default base actions are assumed
+		{golden: "raw-commands-plugins.yaml", sources: []string{"plugins"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.golden, func(t *testing.T) {
+			sources := fixtureSources(tt.sources...)
+			controllers, err := Collect(sources)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var out bytes.Buffer
+			if err := WriteCatalog(&out, controllers, sources, ""); err != nil {
+				t.Fatal(err)
+			}
+			assertGolden(t, tt.golden, out.Bytes())
+		})
+	}
+}
+
+func TestBuildOpenAPI(t *testing.T) {
+	sources := fixtureSources("core", "plugins")
+	controllers, err := Collect(sources)
+	if err != nil {
+		t.Fatal(err)
+	}
+	endpoints := []Endpoint{}
+	for _, controller := range controllers {
+		endpoints = append(endpoints, controller...)
+	}
+	out, err := json.MarshalIndent(buildOpenAPI(endpoints, sources), "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertGolden(t, "openapi.json", append(out, '\n'))
+}
+
+func TestWriteAPIDoc(t *testing.T) {
+	controllers, err := Collect(fixtureSources("core", "plugins"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	endpoints := []Endpoint{}
+	for _, controller := range controllers {
+		endpoints = append(endpoints, controller...)
+	}
+	for _, format := range []string{FormatMarkdown, FormatMan} {
+		t.Run(format, func(t *testing.T) {
+			outputDir := t.TempDir()
+			if err := writeAPIDoc(endpoints, format, outputDir); err != nil {
+				t.Fatal(err)
+			}
+			files, err := os.ReadDir(outputDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, f := range files {
+				out, err := os.ReadFile(filepath.Join(outputDir, f.Name()))
+				if err != nil {
+					t.Fatal(err)
+				}
+				assertGolden(t, filepath.Join("apidoc", f.Name()), out)
+			}
+		})
+	}
+}
+
+func TestWriteSDK(t *testing.T) {
+	controllers, err := Collect(fixtureSources("core", "plugins"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	endpoints := []Endpoint{}
+	for _, controller := range controllers {
+		endpoints = append(endpoints, controller...)
+	}
+	// the SDK is written into its own module, which uses the pkg/api client of this one, so that the import paths
+	// do not depend on the temporary directory and the generated code can be compiled
+	root, err := filepath.Abs(filepath.Join("..", ".."))
+	if err != nil {
+		t.Fatal(err)
+	}
+	moduleDir := t.TempDir()
+	goMod := "module example.com/opnsense\n\ngo 1.21\n\nrequire github.com/thedataflows/opnsense-cli v0.0.0\n\nreplace github.com/thedataflows/opnsense-cli => " + root + "\n"
+	if err := os.WriteFile(filepath.Join(moduleDir, "go.mod"), []byte(goMod), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	goSum, err := os.ReadFile(filepath.Join(root, "go.sum"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(moduleDir, "go.sum"), goSum, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	outputDir := filepath.Join(moduleDir, "sdk")
+	if err := writeSDK(endpoints, fixtureSources("core", "plugins"), outputDir); err != nil {
+		t.Fatal(err)
+	}
+	err = filepath.WalkDir(outputDir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		out, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(outputDir, path)
+		if err != nil {
+			return err
+		}
+		assertGolden(t, filepath.Join("sdk", rel), out)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	goTool, err := exec.LookPath("go")
+	if err != nil || testing.Short() {
+		t.Skip("not compiling the generated SDK")
+	}
+	for _, args := range [][]string{{"build", "./..."}, {"vet", "./..."}} {
+		cmd := exec.Command(goTool, args...)
+		cmd.Dir = moduleDir
+		cmd.Env = append(os.Environ(), "GOFLAGS=-mod=mod", "GOPROXY=off", "GOWORK=off")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Errorf("go %v of the generated SDK failed: %v\n%s", args, err, out)
+		}
+	}
+}
+
+func TestFieldSchema(t *testing.T) {
+	tests := []struct {
+		name    string
+		field   catalog.Field
+		partial bool
+		want    *openAPISchema
+	}{
+		{
+			name:  "mask",
+			field: catalog.Field{Name: "name", Type: "TextField", Mask: "/^[a-z]+$/"},
+			want:  &openAPISchema{Type: "string", Description: "TextField", Pattern: "^[a-z]+$"},
+		},
+		{
+			// ECMA-262 has no inline flags
+			name:  "case insensitive mask",
+			field: catalog.Field{Name: "name", Type: "TextField", Mask: "/^[a-z]+$/i"},
+			want:  &openAPISchema{Type: "string", Description: "TextField, case insensitive mask /^[a-z]+$/i"},
+		},
+		{
+			name: "required",
+			field: catalog.Field{Name: "general", Type: catalog.ContainerType, Fields: []catalog.Field{
+				{Name: "name", Type: "TextField", Required: true},
+				{Name: "enabled", Type: "TextField", Required: true, Default: "1"},
+			}},
+			want: &openAPISchema{Type: "object", Required: []string{"name"}, Properties: map[string]*openAPISchema{
+				"name":    {Type: "string", Description: "TextField"},
+				"enabled": {Type: "string", Description: "TextField", Default: "1"},
+			}},
+		},
+		{
+			name: "partial",
+			field: catalog.Field{Name: "general", Type: catalog.ContainerType, Fields: []catalog.Field{
+				{Name: "name", Type: "TextField", Required: true},
+			}},
+			partial: true,
+			want: &openAPISchema{Type: "object", Properties: map[string]*openAPISchema{
+				"name": {Type: "string", Description: "TextField"},
+			}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := fieldSchema(&tt.field, tt.partial); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("fieldSchema() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
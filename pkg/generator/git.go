@@ -0,0 +1,101 @@
+package generator
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/thedataflows/go-commons/pkg/file"
+	"github.com/thedataflows/go-commons/pkg/log"
+)
+
+// cloneGitRepo clones or pulls a git repository to a local directory, then checks out ref, when given
+func cloneGitRepo(repoURL string, destinationDir string, ref string) error {
+	destinationDir = filepath.ToSlash(destinationDir)
+
+	if file.IsDirectory(filepath.Join(destinationDir, ".git")) {
+		// Open the repository
+		r, err := git.PlainOpen(destinationDir)
+		if err != nil {
+			return fmt.Errorf("error opening repository: %w", err)
+		}
+
+		if len(ref) > 0 {
+			log.Infof("Fetching changes from '%s' to '%s'", repoURL, destinationDir)
+			err = r.Fetch(&git.FetchOptions{
+				Tags:     git.AllTags,
+				Progress: os.Stderr,
+			})
+			if err != nil && err != git.NoErrAlreadyUpToDate {
+				return fmt.Errorf("error fetching changes: %w", err)
+			}
+			return checkoutRef(r, ref)
+		}
+
+		head, err := r.Head()
+		if err != nil {
+			return fmt.Errorf("error reading HEAD: %w", err)
+		}
+		if !head.Name().IsBranch() {
+			return fmt.Errorf("'%s' has a detached HEAD, checked out by a previous --ref, use --ref with a branch name to update it", destinationDir)
+		}
+
+		// Get the working directory for the repository
+		w, err := r.Worktree()
+		if err != nil {
+			return fmt.Errorf("error getting work tree: %w", err)
+		}
+
+		log.Infof("Pulling changes from '%s' to '%s'", repoURL, destinationDir)
+		// Pull the latest changes from the remote repository
+		err = w.Pull(&git.PullOptions{
+			Progress: os.Stderr,
+		})
+		if err != nil && err != git.NoErrAlreadyUpToDate {
+			return fmt.Errorf("error pulling changes: %w", err)
+		}
+		return nil
+	}
+
+	log.Infof("Cloning '%s' to '%s'", repoURL, destinationDir)
+	r, err := git.PlainClone(destinationDir,
+		false,
+		&git.CloneOptions{
+			URL:      repoURL,
+			Progress: os.Stderr,
+		})
+	if err != nil {
+		return fmt.Errorf("error cloning repository: %w", err)
+	}
+	if len(ref) > 0 {
+		return checkoutRef(r, ref)
+	}
+	return nil
+}
+
+// checkoutRef checks out a branch, tag or commit, preferring the remote branches to the local ones
+func checkoutRef(r *git.Repository, ref string) error {
+	hash, err := r.ResolveRevision(plumbing.Revision("refs/remotes/origin/" + ref))
+	if err != nil {
+		hash, err = r.ResolveRevision(plumbing.Revision(ref))
+	}
+	if err != nil {
+		return fmt.Errorf("error resolving ref '%s': %w", ref, err)
+	}
+
+	w, err := r.Worktree()
+	if err != nil {
+		return fmt.Errorf("error getting work tree: %w", err)
+	}
+	log.Infof("Checking out '%s' (%s)", ref, hash)
+	err = w.Checkout(&git.CheckoutOptions{
+		Hash:  *hash,
+		Force: true,
+	})
+	if err != nil {
+		return fmt.Errorf("error checking out '%s': %w", ref, err)
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package generator
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/thedataflows/opnsense-cli/pkg/catalog"
+)
+
+// inputRe matches the request inputs read by name, e.g. $this->request->getPost("destination", "string", null).
+// The optional second and third arguments are the filter and the default value
+var inputRe = regexp.MustCompile(`request->(getPost|hasPost|getQuery|hasQuery|get|has)\(\s*['"]([\w.\[\]-]+)['"]\s*(?:,\s*['"](\w*)['"]\s*)?(?:,\s*([^,)]+?)\s*)?\)`)
+
+// detectInputs returns the query parameters and the posted body fields read by the action code, in order of appearance.
+// request->get() reads the query parameters, and the posted fields only for forms, so its names are query parameters
+func detectInputs(code string) (query []catalog.Input, post []catalog.Input) {
+	seen := map[string]bool{}
+	for _, m := range inputRe.FindAllStringSubmatch(code, -1) {
+		input := catalog.Input{Name: m[2], Filter: m[3], Default: strings.Trim(m[4], `'"`)}
+		if input.Default == "null" {
+			input.Default = ""
+		}
+		isPost := strings.HasSuffix(m[1], "Post")
+		key := input.Name
+		if isPost {
+			key = "post:" + key
+		}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		if isPost {
+			post = append(post, input)
+		} else {
+			query = append(query, input)
+		}
+	}
+	return query, post
+}
@@ -0,0 +1,75 @@
+package generator
+
+import (
+	"net/http"
+	"regexp"
+	"sort"
+
+	"github.com/thedataflows/opnsense-cli/pkg/catalog"
+)
+
+// methodRule is a code pattern showing which HTTP methods an action accepts
+type methodRule struct {
+	re      *regexp.Regexp
+	methods []string
+	// exclusive rules reject the other methods, e.g. actions checking isPost() fail when called with GET
+	exclusive bool
+}
+
+// methodRules are checked against the body of each action. Matches are recorded as evidence
+var methodRules = []methodRule{
+	// explicit request checks and POST data, usually on $this->request
+	{regexp.MustCompile(`->isPost\(\)`), []string{http.MethodPost}, true},
+	{regexp.MustCompile(`->(?:getPost|hasPost)\([^)]*\)`), []string{http.MethodPost}, true},
+	{regexp.MustCompile(`->getJsonRawBody\([^)]*\)`), []string{http.MethodPost}, true},
+	{regexp.MustCompile(`->isGet\(\)`), []string{http.MethodGet}, true},
+	{regexp.MustCompile(`->(?:getQuery|hasQuery)\([^)]*\)`), []string{http.MethodGet}, false},
+	// ApiMutableModelControllerBase helpers, the mutating ones failing unless called with POST
+	{regexp.MustCompile(`\$this->(?:add|set|del|toggle)Base\([^)]*\)`), []string{http.MethodPost}, true},
+	{regexp.MustCompile(`\$this->getBase\([^)]*\)`), []string{http.MethodGet}, false},
+	// search helpers read the bootgrid parameters with request->get, from the query or the posted form
+	{regexp.MustCompile(`\$this->search(?:Recordset)?Base\([^)]*\)`), []string{http.MethodGet, http.MethodPost}, false},
+}
+
+// detectMethods returns the HTTP methods accepted by the action code, with the evidence for each. The main
+// method is POST when GET is not accepted, else GET. Without evidence, GET is assumed and uncertain is true
+func detectMethods(code string) (method string, methods []catalog.MethodEvidence, uncertain bool) {
+	evidence := map[string][]string{}
+	exclusive := map[string][]string{}
+	for _, rule := range methodRules {
+		for _, match := range rule.re.FindAllString(code, -1) {
+			for _, m := range rule.methods {
+				if !contains(evidence[m], match) {
+					evidence[m] = append(evidence[m], match)
+				}
+				if rule.exclusive && !contains(exclusive[m], match) {
+					exclusive[m] = append(exclusive[m], match)
+				}
+			}
+		}
+	}
+	if len(evidence) == 0 {
+		return http.MethodGet, nil, true
+	}
+	if len(exclusive) > 0 {
+		evidence = exclusive
+	}
+	return mainMethod(evidence), methodEvidence(evidence), false
+}
+
+func mainMethod(evidence map[string][]string) string {
+	if _, ok := evidence[http.MethodGet]; !ok {
+		return http.MethodPost
+	}
+	return http.MethodGet
+}
+
+// methodEvidence converts the evidence to a list sorted by method
+func methodEvidence(evidence map[string][]string) []catalog.MethodEvidence {
+	methods := make([]catalog.MethodEvidence, 0, len(evidence))
+	for m, e := range evidence {
+		methods = append(methods, catalog.MethodEvidence{Method: m, Evidence: e})
+	}
+	sort.Slice(methods, func(i, j int) bool { return methods[i].Method < methods[j].Method })
+	return methods
+}
@@ -0,0 +1,321 @@
+package generator
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+	"github.com/thedataflows/opnsense-cli/pkg/catalog"
+)
+
+const openAPIVersion = "3.1.0"
+
+type openAPIDocument struct {
+	OpenAPI    string                                  `json:"openapi"`
+	Info       openAPIInfo                             `json:"info"`
+	Servers    []openAPIServer                         `json:"servers"`
+	Security   []map[string][]string                   `json:"security"`
+	Tags       []openAPITag                            `json:"tags"`
+	Paths      map[string]map[string]*openAPIOperation `json:"paths"`
+	Components openAPIComponents                       `json:"components"`
+}
+
+type openAPIInfo struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+type openAPIServer struct {
+	URL       string                           `json:"url"`
+	Variables map[string]openAPIServerVariable `json:"variables,omitempty"`
+}
+
+type openAPIServerVariable struct {
+	Default     string `json:"default"`
+	Description string `json:"description,omitempty"`
+}
+
+type openAPITag struct {
+	Name string `json:"name"`
+}
+
+type openAPIOperation struct {
+	OperationID string                     `json:"operationId"`
+	Summary     string                     `json:"summary,omitempty"`
+	Tags        []string                   `json:"tags"`
+	Parameters  []openAPIParameter         `json:"parameters,omitempty"`
+	RequestBody *openAPIRequestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]openAPIResponse `json:"responses"`
+	// Plugin is the package of the plugin providing the endpoint, to install before calling it
+	Plugin string `json:"x-opnsense-plugin,omitempty"`
+}
+
+type openAPIParameter struct {
+	Name        string         `json:"name"`
+	In          string         `json:"in"`
+	Required    bool           `json:"required"`
+	Description string         `json:"description,omitempty"`
+	Schema      *openAPISchema `json:"schema"`
+}
+
+type openAPIRequestBody struct {
+	Required bool                        `json:"required"`
+	Content  map[string]openAPIMediaType `json:"content"`
+}
+
+type openAPIMediaType struct {
+	Schema *openAPISchema `json:"schema"`
+}
+
+type openAPIResponse struct {
+	Description string                      `json:"description"`
+	Content     map[string]openAPIMediaType `json:"content,omitempty"`
+}
+
+type openAPIComponents struct {
+	SecuritySchemes map[string]openAPISecurityScheme `json:"securitySchemes"`
+	Schemas         map[string]*openAPISchema        `json:"schemas,omitempty"`
+}
+
+type openAPISecurityScheme struct {
+	Type        string `json:"type"`
+	Scheme      string `json:"scheme"`
+	Description string `json:"description,omitempty"`
+}
+
+type openAPISchema struct {
+	Ref                  string                    `json:"$ref,omitempty"`
+	Type                 string                    `json:"type,omitempty"`
+	Description          string                    `json:"description,omitempty"`
+	Enum                 []string                  `json:"enum,omitempty"`
+	Default              string                    `json:"default,omitempty"`
+	Pattern              string                    `json:"pattern,omitempty"`
+	Properties           map[string]*openAPISchema `json:"properties,omitempty"`
+	AdditionalProperties *openAPISchema            `json:"additionalProperties,omitempty"`
+	Required             []string                  `json:"required,omitempty"`
+}
+
+// openAPIParam is an endpoint parameter parsed from the PHP signature, e.g. '$zoneid=0'
+type openAPIParam struct {
+	Name     string
+	Default  string
+	Optional bool
+}
+
+func parseOpenAPIParams(parameters []string) []openAPIParam {
+	params := make([]openAPIParam, 0, len(parameters))
+	for _, p := range parameters {
+		name, def, optional := strings.Cut(strings.TrimPrefix(p, "$"), "=")
+		def = strings.Trim(def, `'"`)
+		if def == "null" {
+			def = ""
+		}
+		params = append(params, openAPIParam{Name: name, Default: def, Optional: optional})
+	}
+	return params
+}
+
+// buildOpenAPI describes the callable endpoints as an OpenAPI document. Optional parameters
+// result in one path per number of parameters given, as path parameters are always required
+func buildOpenAPI(endpoints []Endpoint, sources []*Source) *openAPIDocument {
+	description := []string{"Generated from the OPNsense controllers and models by opnsense-cli, sources:"}
+	version := "latest"
+	for _, s := range sources {
+		description = append(description, fmt.Sprintf("- %s", s))
+		if len(s.Tag) > 0 && version == "latest" {
+			version = s.Tag
+		}
+	}
+	doc := &openAPIDocument{
+		OpenAPI: openAPIVersion,
+		Info: openAPIInfo{
+			Title:       "OPNsense API",
+			Description: strings.Join(description, "\n"),
+			Version:     version,
+		},
+		Servers: []openAPIServer{{
+			URL: "https://{host}/api",
+			Variables: map[string]openAPIServerVariable{
+				"host": {Default: "opnsense.local", Description: "OPNsense host"},
+			},
+		}},
+		Security: []map[string][]string{{"basicAuth": {}}},
+		Tags:     []openAPITag{},
+		Paths:    map[string]map[string]*openAPIOperation{},
+		Components: openAPIComponents{
+			SecuritySchemes: map[string]openAPISecurityScheme{
+				"basicAuth": {Type: "http", Scheme: "basic", Description: "API key as user name and API secret as password"},
+			},
+			Schemas: map[string]*openAPISchema{},
+		},
+	}
+
+	modules := map[string]bool{}
+	for _, e := range endpoints {
+		if e.IsAbstract {
+			continue
+		}
+		modules[e.Module] = true
+
+		var requestBody *openAPIRequestBody
+		if e.Body != nil {
+			// add* creates a new node, so its required fields must be set. set* only updates the given fields
+			create := strings.HasPrefix(e.Command, "add")
+			name := openAPISchemaName(e.Body)
+			if !create {
+				name += "_partial"
+			}
+			doc.Components.Schemas[name] = fieldsSchema(e.Body.Fields, !create)
+			requestBody = &openAPIRequestBody{
+				Required: create,
+				Content: map[string]openAPIMediaType{
+					"application/json": {Schema: &openAPISchema{
+						Type:       "object",
+						Properties: map[string]*openAPISchema{e.Body.Key: {Ref: "#/components/schemas/" + name}},
+						Required:   []string{e.Body.Key},
+					}},
+				},
+			}
+		} else if len(e.Post) > 0 {
+			schema := &openAPISchema{Type: "object", Properties: map[string]*openAPISchema{}}
+			for _, in := range e.Post {
+				schema.Properties[in.Name] = inputSchema(in)
+			}
+			requestBody = &openAPIRequestBody{
+				Content: map[string]openAPIMediaType{"application/json": {Schema: schema}},
+			}
+		}
+
+		params := parseOpenAPIParams(e.Parameters)
+		required := 0
+		for required < len(params) && !params[required].Optional {
+			required++
+		}
+		for n := required; n <= len(params); n++ {
+			path := fmt.Sprintf("/%s/%s/%s", e.Module, e.Controller, e.Command)
+			operationID := fmt.Sprintf("%s_%s_%s", e.Module, e.Controller, e.Command)
+			op := &openAPIOperation{
+				Summary:     fmt.Sprintf("%s %s %s", e.Module, e.Controller, e.Command),
+				Tags:        []string{e.Module},
+				RequestBody: requestBody,
+				Plugin:      e.Plugin,
+				Responses: map[string]openAPIResponse{
+					"200": {
+						Description: "Response",
+						Content:     map[string]openAPIMediaType{"application/json": {Schema: &openAPISchema{}}},
+					},
+				},
+			}
+			for _, p := range params[:n] {
+				path = fmt.Sprintf("%s/{%s}", path, p.Name)
+				operationID = fmt.Sprintf("%s_%s", operationID, p.Name)
+				schema := &openAPISchema{Type: "string", Default: p.Default}
+				op.Parameters = append(op.Parameters, openAPIParameter{Name: p.Name, In: "path", Required: true, Schema: schema})
+			}
+			for _, in := range e.Query {
+				op.Parameters = append(op.Parameters, openAPIParameter{Name: in.Name, In: "query", Schema: inputSchema(in)})
+			}
+			op.OperationID = operationID
+			if _, ok := doc.Paths[path]; !ok {
+				doc.Paths[path] = map[string]*openAPIOperation{}
+			}
+			doc.Paths[path][strings.ToLower(e.Method)] = op
+		}
+	}
+
+	names := make([]string, 0, len(modules))
+	for m := range modules {
+		names = append(names, m)
+	}
+	sort.Strings(names)
+	for _, m := range names {
+		doc.Tags = append(doc.Tags, openAPITag{Name: m})
+	}
+	return doc
+}
+
+// openAPISchemaName returns the component name of a body schema, e.g. OPNsense.Firewall.Alias.aliases.alias
+func openAPISchemaName(body *catalog.Body) string {
+	name := strings.ReplaceAll(body.Model, "/", ".")
+	if len(body.Path) > 0 {
+		name = fmt.Sprintf("%s.%s", name, body.Path)
+	}
+	return regexp.MustCompile(`[^a-zA-Z0-9._-]`).ReplaceAllString(name, "_")
+}
+
+// fieldsSchema returns the schema of an object holding the given model fields. Unless partial, as for set* updates,
+// required fields without default are required
+func fieldsSchema(fields []catalog.Field, partial bool) *openAPISchema {
+	schema := &openAPISchema{Type: "object", Properties: map[string]*openAPISchema{}}
+	for i := range fields {
+		f := &fields[i]
+		schema.Properties[f.Name] = fieldSchema(f, partial)
+		if f.Required && len(f.Default) == 0 && !partial {
+			schema.Required = append(schema.Required, f.Name)
+		}
+	}
+	return schema
+}
+
+// fieldSchema returns the schema of a model field. OPNsense sends and receives values as strings
+func fieldSchema(f *catalog.Field, partial bool) *openAPISchema {
+	switch {
+	case f.Type == catalog.ContainerType:
+		return fieldsSchema(f.Fields, partial)
+	case f.IsArray():
+		return &openAPISchema{
+			Type:                 "object",
+			Description:          fmt.Sprintf("%s items keyed by uuid", f.Type),
+			AdditionalProperties: fieldsSchema(f.Fields, partial),
+		}
+	}
+
+	schema := &openAPISchema{Type: "string", Description: f.Type, Default: f.Default}
+	switch {
+	case f.Type == "BooleanField":
+		schema.Enum = []string{"0", "1"}
+	case len(f.Options) > 0 && f.Multiple:
+		schema.Description = fmt.Sprintf("%s, comma separated list of: %s", f.Type, strings.Join(f.Options, ", "))
+	case len(f.Options) > 0:
+		schema.Enum = f.Options
+		if !f.Required {
+			schema.Enum = append([]string{""}, f.Options...)
+		}
+	}
+	// ECMA-262 patterns have no inline flags, case insensitive masks are only described
+	if pattern := catalog.MaskPattern(f.Mask); strings.HasPrefix(pattern, "(?i)") {
+		schema.Description = fmt.Sprintf("%s, case insensitive mask %s", schema.Description, f.Mask)
+	} else {
+		schema.Pattern = pattern
+	}
+	return schema
+}
+
+// inputSchema returns the schema of a query parameter or body field read by the controller, described by its filter
+func inputSchema(in catalog.Input) *openAPISchema {
+	schema := &openAPISchema{Type: "string", Default: in.Default}
+	if len(in.Filter) > 0 {
+		schema.Description = fmt.Sprintf("filtered as %s", in.Filter)
+	}
+	return schema
+}
+
+// writeOpenAPI writes the OpenAPI document to fileName, as JSON for .json files and YAML otherwise
+func writeOpenAPI(doc *openAPIDocument, fileName string) error {
+	contents, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(filepath.Ext(fileName), ".json") {
+		if contents, err = yaml.JSONToYAML(contents); err != nil {
+			return err
+		}
+	}
+	return os.WriteFile(filepath.Clean(fileName), contents, 0o600)
+}
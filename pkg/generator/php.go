@@ -0,0 +1,395 @@
+/**
+*
+* This file was converted from https://github.com/opnsense/docs/blob/master/collect_api_endpoints.py
+*
+**/
+
+package generator
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/thedataflows/go-commons/pkg/log"
+	"github.com/thedataflows/opnsense-cli/pkg/catalog"
+)
+
+var (
+	defaultBaseMethods = map[string][]map[string]string{
+		"ApiMutableModelControllerBase": {
+			{
+				"command":    "set",
+				"parameters": "",
+				"method":     "POST",
+			},
+			{
+				"command":    "get",
+				"parameters": "",
+				"method":     "GET",
+			},
+		},
+		"ApiMutableServiceControllerBase": {
+			{
+				"command":    "status",
+				"parameters": "",
+				"method":     "GET",
+			},
+			{
+				"command":    "start",
+				"parameters": "",
+				"method":     "POST",
+			},
+			{
+				"command":    "stop",
+				"parameters": "",
+				"method":     "POST",
+			},
+			{
+				"command":    "restart",
+				"parameters": "",
+				"method":     "POST",
+			},
+			{
+				"command":    "reconfigure",
+				"parameters": "",
+				"method":     "POST",
+			},
+		},
+	}
+)
+
+type Endpoint struct {
+	Method        string
+	Module        string
+	Controller    string
+	IsAbstract    bool
+	BaseClass     string
+	Command       string
+	Parameters    []string
+	Filename      string
+	ModelFilename string
+	Type          string
+	// Body is the request body schema of add*/set* endpoints, when the model is known
+	Body *catalog.Body
+	// Response is the response schema of get* endpoints, when the model is known
+	Response *catalog.Body
+	// Search is true for endpoints searching the model, returning rows
+	Search bool
+	// Methods are the accepted HTTP methods, with the code showing them
+	Methods []catalog.MethodEvidence
+	// MethodUncertain is true when Method is assumed, as no evidence was found
+	MethodUncertain bool
+	// Query are the query parameters read by the action
+	Query []catalog.Input
+	// Post are the body fields read by the action with getPost, at the top level of the JSON body
+	Post []catalog.Input
+	// Plugin is the package of the plugin providing the endpoint, e.g. os-haproxy, empty for the core
+	Plugin string
+}
+
+// phpController is a controller class parsed from a PHP file
+type phpController struct {
+	// Class and BaseClass are fully qualified, e.g. OPNsense\Firewall\Api\AliasController
+	Class      string
+	BaseClass  string
+	IsAbstract bool
+	// Module and Controller are empty for base classes, like ApiControllerBase, which are not callable
+	Module     string
+	Controller string
+	Filename   string
+	// Plugin is the package of the plugin defining the class, empty for the core
+	Plugin string
+	// ModelClass, ModelName and ServiceClass are the static properties set by the class, inherited when empty
+	ModelClass   string
+	ModelName    string
+	ServiceClass string
+	Actions      []phpAction
+	// appLocation is the mvc/app directory holding the models
+	appLocation string
+}
+
+// phpAction is a public action method of a controller, e.g. searchItemAction
+type phpAction struct {
+	Command    string
+	Parameters []string
+	Code       string
+}
+
+// parseAPIPHP parses the controller class of a PHP file, returning nil when the file declares no class
+func parseAPIPHP(srcFilename string) (*phpController, error) {
+	baseFilename := filepath.Base(srcFilename)
+	splitPath := strings.Split(srcFilename, "/")
+
+	data, err := os.ReadFile(srcFilename)
+	if err != nil {
+		return nil, err
+	}
+	dataStr := string(data)
+
+	m := regexp.MustCompile(`(?m)^\s*(abstract\s+)?(?:final\s+)?class\s+(\w+)(?:\s+extends\s+([\w\\]+))?`).FindStringSubmatch(dataStr)
+	if m == nil {
+		return nil, nil
+	}
+	namespace := ""
+	if n := regexp.MustCompile(`(?m)^\s*namespace\s+([\w\\]+)\s*;`).FindStringSubmatch(dataStr); n != nil {
+		namespace = n[1]
+	}
+	uses := map[string]string{}
+	for _, u := range regexp.MustCompile(`(?m)^\s*use\s+\\?([\w\\]+)(?:\s+as\s+(\w+))?\s*;`).FindAllStringSubmatch(dataStr, -1) {
+		alias := u[2]
+		if len(alias) == 0 {
+			alias = u[1][strings.LastIndex(u[1], `\`)+1:]
+		}
+		uses[alias] = u[1]
+	}
+	c := &phpController{
+		Class:       qualifyClass(namespace, nil, m[2]),
+		IsAbstract:  len(m[1]) > 0,
+		Filename:    baseFilename,
+		appLocation: strings.Join(splitPath[:len(splitPath)-5], "/"),
+	}
+	if len(m[3]) > 0 {
+		c.BaseClass = qualifyClass(namespace, uses, m[3])
+	}
+	if strings.HasSuffix(baseFilename, "Controller.php") {
+		c.Controller = strings.ToLower(
+			regexp.MustCompile(`([a-z0-9])([A-Z])`).ReplaceAllString(
+				strings.TrimSuffix(baseFilename, "Controller.php"),
+				"${1}_${2}",
+			),
+		)
+		c.Module = strings.ToLower(splitPath[len(splitPath)-3])
+	}
+	staticProperty := func(name string) string {
+		p := regexp.MustCompile(`\sprotected\sstatic\s\$` + name + `\s=\s['|"]([\w|\\]*)['|"];`).FindStringSubmatch(dataStr)
+		if p == nil {
+			return ""
+		}
+		return p[1]
+	}
+	c.ModelClass = staticProperty("internalModelClass")
+	c.ModelName = staticProperty("internalModelName")
+	c.ServiceClass = staticProperty("internalServiceClass")
+
+	re := regexp.MustCompile(`(\n\s+(private|public|protected)\s+function\s+(\w+)\((.*)\))`)
+	functionCallouts := re.FindAllStringSubmatch(dataStr, -1)
+	for idx, function := range functionCallouts {
+		beginMarker := strings.Index(dataStr, functionCallouts[idx][0])
+		endMarker := len(dataStr)
+		if idx+1 < len(functionCallouts) {
+			endMarker = strings.Index(dataStr, functionCallouts[idx+1][0])
+		}
+		if function[2] != "public" || !strings.HasSuffix(function[3], "Action") {
+			continue
+		}
+		parameters := strings.Split(strings.ReplaceAll(function[4], " ", ""), ",")
+		if parameters[0] == "" {
+			parameters = nil
+		}
+		c.Actions = append(c.Actions, phpAction{
+			Command:    function[3][:len(function[3])-6],
+			Parameters: parameters,
+			Code:       dataStr[beginMarker+len(function[0]) : endMarker],
+		})
+	}
+	return c, nil
+}
+
+// endpoints returns the endpoints of the controller: its actions and the ones inherited from the base classes in
+// controllers, overridden by the subclasses. Base classes missing from controllers provide their defaultBaseMethods
+func (c *phpController) endpoints(controllers map[string]*phpController) []Endpoint {
+	chain := c.ancestors(controllers)
+	root := chain[len(chain)-1].BaseClass
+	modelClass, modelName := "", ""
+	for _, a := range chain {
+		if len(modelClass) == 0 {
+			modelClass = a.ModelClass
+			if len(modelClass) == 0 {
+				modelClass = a.ServiceClass
+			}
+		}
+		if len(modelName) == 0 {
+			modelName = a.ModelName
+		}
+	}
+	modelClass = strings.ReplaceAll(strings.TrimPrefix(modelClass, `\`), `\`, "/")
+	modelFilename := ""
+	var model *catalog.Model
+	if len(modelClass) > 0 {
+		modelXML := fmt.Sprintf("%s/models/%s.xml", c.appLocation, modelClass)
+		if _, err := os.Stat(modelXML); err == nil {
+			modelFilename = strings.ReplaceAll(modelXML, "//", "/")
+			var err error
+			if model, err = catalog.ParseModelFile(modelFilename); err != nil {
+				log.Warnf("Skipping model schema: %v", err)
+			}
+		}
+	}
+	bodyRe := regexp.MustCompile(`\$this->(?:add|set)Base\(\s*['"](\w+)['"]\s*,\s*['"]([\w.]+)['"]`)
+	responseRe := regexp.MustCompile(`\$this->getBase\(\s*['"](\w+)['"]\s*,\s*['"]([\w.]+)['"]`)
+
+	baseClass := shortClassName(c.BaseClass)
+	newEndpoint := func(command string, parameters []string) Endpoint {
+		record := Endpoint{
+			Module:        c.Module,
+			Controller:    c.Controller,
+			IsAbstract:    c.IsAbstract,
+			BaseClass:     baseClass,
+			Command:       command,
+			Parameters:    parameters,
+			Filename:      c.Filename,
+			ModelFilename: modelFilename,
+			Plugin:        c.Plugin,
+		}
+		if c.IsAbstract {
+			record.Type = "Abstract [non-callable]"
+		} else if strings.Contains(c.Controller, "service") {
+			record.Type = "Service"
+		} else {
+			record.Type = "Resources"
+		}
+		return record
+	}
+
+	byCommand := map[string]Endpoint{}
+	// base classes not parsed, e.g. when only the plugins are read, provide the default actions
+	for _, item := range defaultBaseMethods[shortClassName(root)] {
+		record := newEndpoint(item["command"], nil)
+		if !c.IsAbstract {
+			record.Type = "Service"
+		}
+		record.Method = item["method"]
+		record.Methods = []catalog.MethodEvidence{{
+			Method:   item["method"],
+			Evidence: []string{fmt.Sprintf("inherited %s::%sAction", shortClassName(root), item["command"])},
+		}}
+		switch item["command"] {
+		case "set":
+			record.Body = modelBody(model, modelClass, modelName, "")
+		case "get":
+			record.Response = modelBody(model, modelClass, modelName, "")
+		}
+		byCommand[record.Command] = record
+	}
+	// walk from the farthest base class, so subclasses override the inherited actions
+	for i := len(chain) - 1; i >= 0; i-- {
+		for _, action := range chain[i].Actions {
+			record := newEndpoint(action.Command, action.Parameters)
+			if b := bodyRe.FindStringSubmatch(action.Code); b != nil {
+				record.Body = modelBody(model, modelClass, b[1], b[2])
+			}
+			if r := responseRe.FindStringSubmatch(action.Code); r != nil {
+				record.Response = modelBody(model, modelClass, r[1], r[2])
+			}
+			record.Search = strings.Contains(action.Code, "$this->searchBase(")
+			record.Method, record.Methods, record.MethodUncertain = detectMethods(action.Code)
+			record.Query, record.Post = detectInputs(action.Code)
+			// base class actions, like ApiMutableModelControllerBase::setAction, send and receive the whole model
+			if strings.Contains(action.Code, "static::$internalModelName") && record.Body == nil && record.Response == nil {
+				if record.Method == http.MethodPost {
+					record.Body = modelBody(model, modelClass, modelName, "")
+				} else {
+					record.Response = modelBody(model, modelClass, modelName, "")
+				}
+			}
+			if chain[i] != c {
+				for j := range record.Methods {
+					record.Methods[j].Evidence = append(record.Methods[j].Evidence, fmt.Sprintf("inherited %s::%sAction", shortClassName(chain[i].Class), action.Command))
+				}
+			}
+			byCommand[record.Command] = record
+		}
+	}
+
+	result := make([]Endpoint, 0, len(byCommand))
+	for _, record := range byCommand {
+		result = append(result, record)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Command < result[j].Command
+	})
+	return result
+}
+
+// ancestors returns the controller followed by its base classes found in controllers, nearest first
+func (c *phpController) ancestors(controllers map[string]*phpController) []*phpController {
+	chain := []*phpController{c}
+	seen := map[string]bool{c.Class: true}
+	for base, ok := controllers[c.BaseClass]; ok && !seen[base.Class]; base, ok = controllers[base.BaseClass] {
+		seen[base.Class] = true
+		chain = append(chain, base)
+	}
+	return chain
+}
+
+// qualifyClass returns the fully qualified name of a class referenced in a file of namespace, importing uses
+func qualifyClass(namespace string, uses map[string]string, name string) string {
+	if strings.HasPrefix(name, `\`) {
+		return name[1:]
+	}
+	first, rest, nested := strings.Cut(name, `\`)
+	if imported, ok := uses[first]; ok {
+		if nested {
+			return imported + `\` + rest
+		}
+		return imported
+	}
+	if len(namespace) == 0 {
+		return name
+	}
+	return namespace + `\` + name
+}
+
+// shortClassName returns the class name without namespace
+func shortClassName(class string) string {
+	return class[strings.LastIndex(class, `\`)+1:]
+}
+
+// modelBody returns the schema of a body holding the model node at path under key
+func modelBody(model *catalog.Model, modelClass string, key string, path string) *catalog.Body {
+	if model == nil || key == "" {
+		return nil
+	}
+	fields, ok := catalog.Lookup(model.Fields, path)
+	if !ok {
+		log.Warnf("Model %s has no node '%s'", modelClass, path)
+		return nil
+	}
+	return &catalog.Body{
+		Key:    key,
+		Model:  modelClass,
+		Path:   path,
+		Fields: fields,
+	}
+}
+
+// func sourceURL(repo string, srcFilename string) string {
+// 	parts := strings.Split(srcFilename, "/")
+// 	if repo == "plugins" {
+// 		return fmt.Sprintf("https://github.com/opnsense/plugins/blob/master/%s", strings.Join(parts[findIndex(parts, "src")-2:], "/"))
+// 	}
+// 	return fmt.Sprintf("https://github.com/opnsense/core/blob/master/%s", strings.Join(parts[findIndex(parts, "src"):], "/"))
+// }
+
+// func findIndex(slice []string, val string) int {
+// 	for i, item := range slice {
+// 		if item == val {
+// 			return i
+// 		}
+// 	}
+// 	return -1
+// }
+
+func contains(slice []string, val string) bool {
+	for _, item := range slice {
+		if item == val {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,301 @@
+package generator
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"go/format"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/iancoleman/strcase"
+	"github.com/thedataflows/opnsense-cli/pkg/catalog"
+)
+
+// sdkModule is a generated Go package, one per OPNsense module
+type sdkModule struct {
+	Package     string
+	Name        string
+	Controllers []*sdkController
+	Types       []*sdkType
+	NeedsFmt    bool
+
+	types map[string]*sdkType
+}
+
+type sdkController struct {
+	Name     string
+	Type     string
+	Commands []*sdkCommand
+}
+
+type sdkCommand struct {
+	Name        string
+	Path        string
+	Method      string
+	Params      []sdkParam
+	ParamsType  string
+	RequestType string
+	ResultType  string
+	Search      bool
+}
+
+type sdkParam struct {
+	Name     string
+	Field    string
+	Optional bool
+	Default  string
+}
+
+type sdkType struct {
+	Name   string
+	Doc    string
+	Fields []sdkField
+}
+
+type sdkField struct {
+	Name string
+	Type string
+	JSON string
+	Doc  string
+}
+
+// sdkClient is the root package of the SDK, holding the module clients
+type sdkClient struct {
+	Package    string
+	ImportPath string
+	Modules    []*sdkModule
+	Sources    []string
+}
+
+// goName converts an OPNsense name to an exported go identifier
+func goName(name string) string {
+	n := strcase.ToCamel(name)
+	if len(n) == 0 || !unicode.IsLetter(rune(n[0])) {
+		n = "X" + n
+	}
+	return n
+}
+
+// uniqueName returns name, suffixed with a number when already used
+func uniqueName(name string, used map[string]bool) string {
+	unique := name
+	for i := 2; used[unique]; i++ {
+		unique = fmt.Sprintf("%s%d", name, i)
+	}
+	used[unique] = true
+	return unique
+}
+
+// nodeType registers the struct type holding fields and returns its name
+func (m *sdkModule) nodeType(name string, doc string, fields []catalog.Field) string {
+	if _, ok := m.types[name]; ok {
+		return name
+	}
+	t := &sdkType{Name: name, Doc: doc}
+	m.types[name] = t
+	m.Types = append(m.Types, t)
+
+	used := map[string]bool{}
+	for i := range fields {
+		f := &fields[i]
+		field := sdkField{
+			Name: uniqueName(goName(f.Name), used),
+			JSON: f.Name,
+			Doc:  f.Type,
+		}
+		switch {
+		case f.Type == catalog.ContainerType:
+			field.Type = "*" + m.nodeType(name+goName(f.Name), fmt.Sprintf("%s is the %s container", name+goName(f.Name), f.Name), f.Fields)
+			field.Doc = ""
+		case f.IsArray():
+			field.Type = "map[string]" + m.nodeType(name+goName(f.Name), fmt.Sprintf("%s is an item of %s, keyed by uuid", name+goName(f.Name), f.Name), f.Fields)
+		default:
+			field.Type = "api.Field"
+			attrs := []string{f.Type}
+			if f.Required {
+				attrs = append(attrs, "required")
+			}
+			if len(f.Default) > 0 {
+				attrs = append(attrs, fmt.Sprintf("default %s", f.Default))
+			}
+			if len(f.Options) > 0 {
+				attrs = append(attrs, fmt.Sprintf("options %s", strings.Join(f.Options, "|")))
+			}
+			field.Doc = strings.Join(attrs, ", ")
+		}
+		t.Fields = append(t.Fields, field)
+	}
+	return name
+}
+
+// bodyType registers the type of a request or response body and returns its name
+func (m *sdkModule) bodyType(name string, body *catalog.Body) string {
+	model := body.Model[strings.LastIndex(body.Model, "/")+1:]
+	node := goName(model)
+	for _, p := range strings.Split(body.Path, ".") {
+		if len(p) > 0 {
+			node += goName(p)
+		}
+	}
+	node = m.nodeType(node, fmt.Sprintf("%s is the %s node of model %s", node, body.Path, body.Model), body.Fields)
+	if body.Path == "" {
+		m.types[node].Doc = fmt.Sprintf("%s is the model %s", node, body.Model)
+	}
+
+	if _, ok := m.types[name]; !ok {
+		t := &sdkType{
+			Name:   name,
+			Doc:    fmt.Sprintf("%s is the body holding %s", name, body.Key),
+			Fields: []sdkField{{Name: goName(body.Key), Type: "*" + node, JSON: body.Key}},
+		}
+		m.types[name] = t
+		m.Types = append(m.Types, t)
+	}
+	return name
+}
+
+// buildSDK groups the callable endpoints into one package per module
+func buildSDK(endpoints []Endpoint) []*sdkModule {
+	modules := map[string]*sdkModule{}
+	controllers := map[string]*sdkController{}
+	commandNames := map[string]map[string]bool{}
+	for _, e := range endpoints {
+		if e.IsAbstract {
+			continue
+		}
+		m, ok := modules[e.Module]
+		if !ok {
+			m = &sdkModule{Package: e.Module, Name: goName(e.Module), types: map[string]*sdkType{}}
+			modules[e.Module] = m
+		}
+		controllerKey := e.Module + "/" + e.Controller
+		c, ok := controllers[controllerKey]
+		if !ok {
+			c = &sdkController{Name: goName(e.Controller), Type: goName(e.Controller) + "Controller"}
+			controllers[controllerKey] = c
+			commandNames[controllerKey] = map[string]bool{}
+			m.Controllers = append(m.Controllers, c)
+		}
+
+		cmd := &sdkCommand{
+			Name:   uniqueName(goName(e.Command), commandNames[controllerKey]),
+			Path:   fmt.Sprintf("%s/%s/%s", e.Module, e.Controller, e.Command),
+			Method: e.Method,
+			Search: e.Search,
+		}
+		prefix := c.Name + cmd.Name
+		if len(e.Parameters) > 0 {
+			cmd.ParamsType = prefix + "Params"
+			used := map[string]bool{}
+			for _, p := range parseOpenAPIParams(e.Parameters) {
+				cmd.Params = append(cmd.Params, sdkParam{Name: p.Name, Field: uniqueName(goName(p.Name), used), Optional: p.Optional, Default: p.Default})
+				if !p.Optional {
+					m.NeedsFmt = true
+				}
+			}
+		}
+		if e.Body != nil {
+			cmd.RequestType = m.bodyType(prefix+"Request", e.Body)
+		}
+		switch {
+		case e.Response != nil:
+			cmd.ResultType = "*" + m.bodyType(prefix+"Response", e.Response)
+		case e.Search:
+			cmd.ResultType = "*api.SearchResult"
+		}
+		c.Commands = append(c.Commands, cmd)
+	}
+
+	result := make([]*sdkModule, 0, len(modules))
+	for _, m := range modules {
+		sort.Slice(m.Controllers, func(i, j int) bool { return m.Controllers[i].Name < m.Controllers[j].Name })
+		for _, c := range m.Controllers {
+			sort.Slice(c.Commands, func(i, j int) bool { return c.Commands[i].Name < c.Commands[j].Name })
+		}
+		sort.Slice(m.Types, func(i, j int) bool { return m.Types[i].Name < m.Types[j].Name })
+		result = append(result, m)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Package < result[j].Package })
+	return result
+}
+
+// goModulePath returns the import path of dir, from the go.mod file found in dir or its parents
+func goModulePath(dir string) (string, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for d := abs; ; d = filepath.Dir(d) {
+		f, err := os.Open(filepath.Join(d, "go.mod"))
+		if err == nil {
+			defer f.Close()
+			scanner := bufio.NewScanner(f)
+			for scanner.Scan() {
+				if line := strings.TrimSpace(scanner.Text()); strings.HasPrefix(line, "module ") {
+					rel, err := filepath.Rel(d, abs)
+					if err != nil {
+						return "", err
+					}
+					return filepath.ToSlash(filepath.Join(strings.TrimSpace(line[len("module "):]), rel)), nil
+				}
+			}
+			return "", fmt.Errorf("no module directive in %s", filepath.Join(d, "go.mod"))
+		}
+		if filepath.Dir(d) == d {
+			return "", fmt.Errorf("no go.mod found for %s", dir)
+		}
+	}
+}
+
+// writeGoFile executes the template into a gofmt formatted file
+func writeGoFile(tmpl *template.Template, data interface{}, fileName string) error {
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, data); err != nil {
+		return err
+	}
+	formatted, err := format.Source(out.Bytes())
+	if err != nil {
+		return fmt.Errorf("%s: %w", fileName, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(fileName), 0o750); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Clean(fileName), formatted, 0o600)
+}
+
+// writeSDK writes one package per module and the root client package in outputDir
+func writeSDK(endpoints []Endpoint, sources []*Source, outputDir string) error {
+	importPath, err := goModulePath(outputDir)
+	if err != nil {
+		return err
+	}
+	moduleTmpl, err := template.ParseFS(templates, "templates/sdk_module.gotmpl")
+	if err != nil {
+		return err
+	}
+	clientTmpl, err := template.ParseFS(templates, "templates/sdk_client.gotmpl")
+	if err != nil {
+		return err
+	}
+
+	modules := buildSDK(endpoints)
+	for _, m := range modules {
+		if err := writeGoFile(moduleTmpl, m, filepath.Join(outputDir, m.Package, m.Package+".go")); err != nil {
+			return err
+		}
+	}
+	client := sdkClient{
+		Package:    strings.ToLower(goName(filepath.Base(importPath))),
+		ImportPath: importPath,
+		Modules:    modules,
+	}
+	for _, s := range sources {
+		client.Sources = append(client.Sources, s.String())
+	}
+	return writeGoFile(clientTmpl, client, filepath.Join(outputDir, "client.go"))
+}
@@ -0,0 +1,211 @@
+package generator
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// Source is an OPNsense source tree the endpoints are collected from
+type Source struct {
+	// Name is the repository name, e.g. core
+	Name string
+	// Dir is the directory the sources are read from
+	Dir string
+	// Origin is the directory, tarball or repository URL given
+	Origin string
+	// Ref is the branch, tag or commit requested, if any
+	Ref    string
+	Commit string
+	Tag    string
+}
+
+// String describes the source revision, e.g. 'core: tag 24.7, commit 0123abc'
+func (s *Source) String() string {
+	revision := []string{}
+	if len(s.Ref) > 0 {
+		revision = append(revision, fmt.Sprintf("ref %s", s.Ref))
+	}
+	if len(s.Tag) > 0 {
+		revision = append(revision, fmt.Sprintf("tag %s", s.Tag))
+	}
+	if len(s.Commit) > 0 {
+		revision = append(revision, fmt.Sprintf("commit %s", s.Commit))
+	}
+	if len(revision) == 0 {
+		revision = append(revision, "unknown revision")
+	}
+	return fmt.Sprintf("%s: %s", s.Name, strings.Join(revision, ", "))
+}
+
+// isTarball returns true for file names of tar archives, optionally gzip compressed
+func isTarball(name string) bool {
+	name = strings.ToLower(name)
+	return strings.HasSuffix(name, ".tar") || strings.HasSuffix(name, ".tar.gz") || strings.HasSuffix(name, ".tgz")
+}
+
+// tarballName strips the archive extension, e.g. core-24.7.tar.gz becomes core-24.7
+func tarballName(name string) string {
+	name = filepath.Base(name)
+	for _, ext := range []string{".tar.gz", ".tgz", ".tar"} {
+		if strings.HasSuffix(strings.ToLower(name), ext) {
+			return name[:len(name)-len(ext)]
+		}
+	}
+	return name
+}
+
+// releaseName splits archive names like core-24.7 or plugins-24.7.1 in repository name and release
+var releaseName = regexp.MustCompile(`^([a-z]+)-(\d[\w.]*)$`)
+
+// openSource prepares a --source directory or tarball. Tarballs are extracted to tmpDir
+func openSource(origin string, tmpDir string) (*Source, error) {
+	info, err := os.Stat(origin)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		dir, err := filepath.Abs(origin)
+		if err != nil {
+			return nil, err
+		}
+		source := &Source{Name: filepath.Base(dir), Dir: dir, Origin: origin}
+		if err := source.describeGit(); err != nil {
+			return nil, err
+		}
+		return source, nil
+	}
+	if !isTarball(origin) {
+		return nil, fmt.Errorf("source '%s' is neither a directory nor a tarball", origin)
+	}
+
+	dir, err := os.MkdirTemp(tmpDir, tarballName(origin)+"-")
+	if err != nil {
+		return nil, err
+	}
+	commit, err := extractTarball(origin, dir)
+	if err != nil {
+		return nil, fmt.Errorf("error extracting '%s': %w", origin, err)
+	}
+	source := &Source{Name: tarballName(origin), Dir: dir, Origin: origin, Commit: commit}
+	// archives of GitHub releases hold a single directory named after the release, e.g. core-24.7
+	if entries, err := os.ReadDir(dir); err == nil && len(entries) == 1 && entries[0].IsDir() {
+		source.Name = entries[0].Name()
+	}
+	if m := releaseName.FindStringSubmatch(source.Name); m != nil {
+		source.Name = m[1]
+		source.Tag = m[2]
+	}
+	return source, nil
+}
+
+// describeGit records the commit and tag checked out, when the source is the root of a git working tree. A directory
+// inside another repository, like a test fixture, has no revision of its own
+func (s *Source) describeGit() error {
+	r, err := git.PlainOpen(s.Dir)
+	if err == git.ErrRepositoryNotExists {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error opening repository %s: %w", s.Dir, err)
+	}
+	head, err := r.Head()
+	if err != nil {
+		return fmt.Errorf("error reading HEAD of %s: %w", s.Dir, err)
+	}
+	s.Commit = head.Hash().String()
+	s.Tag = ""
+
+	tags, err := r.Tags()
+	if err != nil {
+		return fmt.Errorf("error reading tags of %s: %w", s.Dir, err)
+	}
+	defer tags.Close()
+	return tags.ForEach(func(ref *plumbing.Reference) error {
+		hash := ref.Hash()
+		// annotated tags point to a tag object
+		if tag, err := r.TagObject(hash); err == nil {
+			hash = tag.Target
+		}
+		// several tags may point to the same commit, keep the greatest for reproducible output
+		if hash == head.Hash() && ref.Name().Short() > s.Tag {
+			s.Tag = ref.Name().Short()
+		}
+		return nil
+	})
+}
+
+// extractTarball extracts the archive to dir, returning the commit recorded by 'git archive', if any
+func extractTarball(tarball string, dir string) (string, error) {
+	f, err := os.Open(filepath.Clean(tarball))
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	var reader io.Reader = f
+	if !strings.HasSuffix(strings.ToLower(tarball), ".tar") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return "", err
+		}
+		defer gz.Close()
+		reader = gz
+	}
+
+	commit := ""
+	tr := tar.NewReader(reader)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return commit, nil
+		}
+		if err != nil {
+			return "", err
+		}
+		// git archive stores the commit in the comment of the global header
+		if c, ok := header.PAXRecords["comment"]; ok && len(commit) == 0 {
+			commit = c
+		}
+
+		target := filepath.Join(dir, filepath.Clean("/"+header.Name))
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0o750); err != nil {
+				return "", err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0o750); err != nil {
+				return "", err
+			}
+			out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+			if err != nil {
+				return "", err
+			}
+			// #nosec G110 -- sources are trusted release archives
+			_, err = io.Copy(out, tr)
+			out.Close()
+			if err != nil {
+				return "", err
+			}
+		}
+	}
+}
+
+// sourcesHeader returns the comment lines recording the sources of a generated file
+func sourcesHeader(sources []*Source) string {
+	var header strings.Builder
+	header.WriteString("# Sources:\n")
+	for _, s := range sources {
+		fmt.Fprintf(&header, "#   %s\n", s)
+	}
+	return header.String()
+}
@@ -0,0 +1,10 @@
+<?php
+namespace OPNsense\Base;
+
+class ApiControllerBase extends ControllerRoot
+{
+    public function beforeExecuteRoute($dispatcher)
+    {
+        return true;
+    }
+}
@@ -0,0 +1,34 @@
+<?php
+namespace OPNsense\Base;
+
+abstract class ApiMutableModelControllerBase extends ApiControllerBase
+{
+    protected static $internalModelName = null;
+    protected static $internalModelClass = null;
+
+    public function getAction()
+    {
+        $result = array();
+        if ($this->request->isGet()) {
+            $result[static::$internalModelName] = $this->getModelNodes();
+        }
+        return $result;
+    }
+
+    public function setAction()
+    {
+        $result = array("result" => "failed");
+        if ($this->request->isPost()) {
+            $mdl = $this->getModel();
+            $mdl->setNodes($this->request->getPost(static::$internalModelName));
+            $result = $this->validateAndSave();
+        }
+        return $result;
+    }
+
+    public function searchBase($path, $fields, $defaultSort = null)
+    {
+        $this->sessionClose();
+        return $this->getModel()->getNodeByReference($path);
+    }
+}
@@ -0,0 +1,45 @@
+<?php
+namespace OPNsense\Base;
+
+abstract class ApiMutableServiceControllerBase extends ApiControllerBase
+{
+    protected static $internalServiceClass = null;
+    protected static $internalServiceName = null;
+
+    public function startAction()
+    {
+        if ($this->request->isPost()) {
+            return array("response" => "started");
+        }
+        return array("response" => array());
+    }
+
+    public function stopAction()
+    {
+        if ($this->request->isPost()) {
+            return array("response" => "stopped");
+        }
+        return array("response" => array());
+    }
+
+    public function restartAction()
+    {
+        if ($this->request->isPost()) {
+            return array("response" => "restarted");
+        }
+        return array("response" => array());
+    }
+
+    public function reconfigureAction()
+    {
+        if ($this->request->isPost()) {
+            return array("status" => "ok");
+        }
+        return array("status" => "failed");
+    }
+
+    public function statusAction()
+    {
+        return array("status" => "running");
+    }
+}
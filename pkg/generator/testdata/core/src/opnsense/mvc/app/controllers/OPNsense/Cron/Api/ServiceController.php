@@ -0,0 +1,18 @@
+<?php
+namespace OPNsense\Cron\Api;
+
+use OPNsense\Base\ApiMutableServiceControllerBase as ServiceBase;
+
+class ServiceController extends ServiceBase
+{
+    protected static $internalServiceClass = '\OPNsense\Cron\Cron';
+    protected static $internalServiceName = 'cron';
+
+    public function reconfigureAction()
+    {
+        if ($this->request->isPost()) {
+            return array("status" => "ok");
+        }
+        return array("status" => "failed");
+    }
+}
@@ -0,0 +1,48 @@
+<?php
+
+namespace OPNsense\Diagnostics\Api;
+
+use OPNsense\Base\ApiControllerBase;
+use OPNsense\Core\Backend;
+
+class InterfaceController extends ApiControllerBase
+{
+    public function getArpAction()
+    {
+        $backend = new Backend();
+        $response = $backend->configdpRun("interface list arp json");
+        return json_decode($response, true);
+    }
+
+    public function flushArpAction()
+    {
+        $result = ["status" => "failed"];
+        if ($this->request->isPost()) {
+            $backend = new Backend();
+            $result["status"] = trim($backend->configdpRun("interface flush arp"));
+        }
+        return $result;
+    }
+
+    public function delRouteAction()
+    {
+        $destination = $this->request->getPost("destination", "string", null);
+        $gateway = $this->request->getPost('gateway');
+        if ($this->request->hasPost("force")) {
+            $destination .= "!";
+        }
+        return ["status" => $destination . $gateway];
+    }
+
+    public function getPfStatesAction()
+    {
+        $ruleid = $this->request->get("ruleid");
+        $filter = $this->request->getQuery("filter", "string", "");
+        return ["rule" => $ruleid, "filter" => $filter];
+    }
+
+    public function searchArpAction()
+    {
+        return $this->searchRecordsetBase([]);
+    }
+}
@@ -0,0 +1,30 @@
+<?php
+namespace OPNsense\Firewall\Api;
+
+use OPNsense\Base\ApiMutableModelControllerBase;
+
+class AliasController extends ApiMutableModelControllerBase
+{
+    protected static $internalModelName = 'alias';
+    protected static $internalModelClass = 'OPNsense\Firewall\Alias';
+
+    public function searchItemAction()
+    {
+        return $this->searchBase("aliases.alias", array('enabled', 'name', 'description'), "name");
+    }
+
+    public function setItemAction($uuid)
+    {
+        return $this->setBase("alias", "aliases.alias", $uuid);
+    }
+
+    public function addItemAction()
+    {
+        return $this->addBase("alias", "aliases.alias");
+    }
+
+    public function delItemAction($uuid)
+    {
+        return $this->delBase("aliases.alias", $uuid);
+    }
+}
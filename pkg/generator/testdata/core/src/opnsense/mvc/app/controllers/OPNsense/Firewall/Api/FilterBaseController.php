@@ -0,0 +1,48 @@
+<?php
+namespace OPNsense\Firewall\Api;
+
+use OPNsense\Base\ApiMutableModelControllerBase;
+
+abstract class FilterBaseController extends ApiMutableModelControllerBase
+{
+    protected static $categorysource = "rules.rule";
+
+    public function searchRuleAction()
+    {
+        $category = $this->request->get('category');
+        return $this->searchBase("rules.rule", array('enabled', 'sequence', 'description'), "sequence");
+    }
+
+    public function getRuleAction($uuid = null)
+    {
+        return $this->getBase("rule", "rules.rule", $uuid);
+    }
+
+    public function addRuleAction()
+    {
+        return $this->addBase("rule", "rules.rule");
+    }
+
+    public function setRuleAction($uuid)
+    {
+        return $this->setBase("rule", "rules.rule", $uuid);
+    }
+
+    public function delRuleAction($uuid)
+    {
+        return $this->delBase("rules.rule", $uuid);
+    }
+
+    public function toggleRuleAction($uuid, $enabled = null)
+    {
+        return $this->toggleBase("rules.rule", $uuid, $enabled);
+    }
+
+    public function applyAction($rollback_revision = null)
+    {
+        if ($this->request->isPost()) {
+            return array("status" => "OK");
+        }
+        return array("status" => "failed");
+    }
+}
@@ -0,0 +1,38 @@
+<?php
+namespace OPNsense\Firewall\Api;
+
+class FilterController extends FilterBaseController
+{
+    protected static $internalModelName = 'filter';
+    protected static $internalModelClass = 'OPNsense\Firewall\Filter';
+
+    public function savepointAction()
+    {
+        if ($this->request->isPost()) {
+            return array("revision" => "1");
+        }
+        return array("status" => "failed");
+    }
+
+    public function cancelRollbackAction($rollback_revision)
+    {
+        if ($this->request->isPost()) {
+            return array("status" => "ok");
+        }
+        return array("status" => "failed");
+    }
+
+    public function revertAction($revision)
+    {
+        if ($this->request->isPost()) {
+            return array("status" => "ok");
+        }
+        return array("status" => "failed");
+    }
+
+    public function getRuleAction($uuid = null)
+    {
+        $category = $this->request->getQuery("category");
+        return $this->getBase("rule", "rules.rule", $uuid);
+    }
+}
@@ -0,0 +1,62 @@
+// Code generated by opnsense-cli generator. DO NOT EDIT.
+
+// Package cron calls the OPNsense cron API
+package cron
+
+import (
+	"context"
+
+	"github.com/thedataflows/opnsense-cli/pkg/api"
+)
+
+// Client holds the cron controllers
+type Client struct {
+	Service *ServiceController
+}
+
+// New returns the cron client calling the API with client
+func New(client *api.Client) *Client {
+	return &Client{
+		Service: &ServiceController{client: client},
+	}
+}
+
+// ServiceController calls the cron Service API commands
+type ServiceController struct {
+	client *api.Client
+}
+
+// Reconfigure calls POST /api/cron/service/reconfigure
+func (c *ServiceController) Reconfigure(ctx context.Context) (*api.Response, error) {
+	method := "POST"
+	var data interface{}
+	return c.client.Call(ctx, method, "cron/service/reconfigure", nil, data)
+}
+
+// Restart calls POST /api/cron/service/restart
+func (c *ServiceController) Restart(ctx context.Context) (*api.Response, error) {
+	method := "POST"
+	var data interface{}
+	return c.client.Call(ctx, method, "cron/service/restart", nil, data)
+}
+
+// Start calls POST /api/cron/service/start
+func (c *ServiceController) Start(ctx context.Context) (*api.Response, error) {
+	method := "POST"
+	var data interface{}
+	return c.client.Call(ctx, method, "cron/service/start", nil, data)
+}
+
+// Status calls GET /api/cron/service/status
+func (c *ServiceController) Status(ctx context.Context) (*api.Response, error) {
+	method := "GET"
+	var data interface{}
+	return c.client.Call(ctx, method, "cron/service/status", nil, data)
+}
+
+// Stop calls POST /api/cron/service/stop
+func (c *ServiceController) Stop(ctx context.Context) (*api.Response, error) {
+	method := "POST"
+	var data interface{}
+	return c.client.Call(ctx, method, "cron/service/stop", nil, data)
+}
@@ -0,0 +1,62 @@
+// Code generated by opnsense-cli generator. DO NOT EDIT.
+
+// Package diagnostics calls the OPNsense diagnostics API
+package diagnostics
+
+import (
+	"context"
+
+	"github.com/thedataflows/opnsense-cli/pkg/api"
+)
+
+// Client holds the diagnostics controllers
+type Client struct {
+	Interface *InterfaceController
+}
+
+// New returns the diagnostics client calling the API with client
+func New(client *api.Client) *Client {
+	return &Client{
+		Interface: &InterfaceController{client: client},
+	}
+}
+
+// InterfaceController calls the diagnostics Interface API commands
+type InterfaceController struct {
+	client *api.Client
+}
+
+// DelRoute calls POST /api/diagnostics/interface/delRoute
+func (c *InterfaceController) DelRoute(ctx context.Context) (*api.Response, error) {
+	method := "POST"
+	var data interface{}
+	return c.client.Call(ctx, method, "diagnostics/interface/delRoute", nil, data)
+}
+
+// FlushArp calls POST /api/diagnostics/interface/flushArp
+func (c *InterfaceController) FlushArp(ctx context.Context) (*api.Response, error) {
+	method := "POST"
+	var data interface{}
+	return c.client.Call(ctx, method, "diagnostics/interface/flushArp", nil, data)
+}
+
+// GetArp calls GET /api/diagnostics/interface/getArp
+func (c *InterfaceController) GetArp(ctx context.Context) (*api.Response, error) {
+	method := "GET"
+	var data interface{}
+	return c.client.Call(ctx, method, "diagnostics/interface/getArp", nil, data)
+}
+
+// GetPfStates calls GET /api/diagnostics/interface/getPfStates
+func (c *InterfaceController) GetPfStates(ctx context.Context) (*api.Response, error) {
+	method := "GET"
+	var data interface{}
+	return c.client.Call(ctx, method, "diagnostics/interface/getPfStates", nil, data)
+}
+
+// SearchArp calls GET /api/diagnostics/interface/searchArp
+func (c *InterfaceController) SearchArp(ctx context.Context) (*api.Response, error) {
+	method := "GET"
+	var data interface{}
+	return c.client.Call(ctx, method, "diagnostics/interface/searchArp", nil, data)
+}
@@ -0,0 +1,369 @@
+// Code generated by opnsense-cli generator. DO NOT EDIT.
+
+// Package firewall calls the OPNsense firewall API
+package firewall
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/thedataflows/opnsense-cli/pkg/api"
+)
+
+// Client holds the firewall controllers
+type Client struct {
+	Alias  *AliasController
+	Filter *FilterController
+}
+
+// New returns the firewall client calling the API with client
+func New(client *api.Client) *Client {
+	return &Client{
+		Alias:  &AliasController{client: client},
+		Filter: &FilterController{client: client},
+	}
+}
+
+// Alias is the model OPNsense/Firewall/Alias
+type Alias struct {
+	Geoip   *AliasGeoip   `json:"geoip,omitempty"`
+	Aliases *AliasAliases `json:"aliases,omitempty"`
+}
+
+// AliasAddItemRequest is the body holding alias
+type AliasAddItemRequest struct {
+	Alias *AliasAliasesAlias `json:"alias,omitempty"`
+}
+
+// AliasAliases is the aliases container
+type AliasAliases struct {
+	Alias map[string]AliasAliasesAlias `json:"alias,omitempty"` // AliasField
+}
+
+// AliasAliasesAlias is the aliases.alias node of model OPNsense/Firewall/Alias
+type AliasAliasesAlias struct {
+	Enabled     api.Field `json:"enabled,omitempty"`     // BooleanField, required, default 1
+	Name        api.Field `json:"name,omitempty"`        // AliasNameField, required
+	Type        api.Field `json:"type,omitempty"`        // OptionField, required, options host|network|port
+	Proto       api.Field `json:"proto,omitempty"`       // OptionField, options IPv4|IPv6
+	Content     api.Field `json:"content,omitempty"`     // AliasContentField
+	Description api.Field `json:"description,omitempty"` // TextField
+}
+
+// AliasGeoip is the geoip container
+type AliasGeoip struct {
+	Url api.Field `json:"url,omitempty"` // TextField
+}
+
+// AliasGetResponse is the body holding alias
+type AliasGetResponse struct {
+	Alias *Alias `json:"alias,omitempty"`
+}
+
+// AliasSetItemRequest is the body holding alias
+type AliasSetItemRequest struct {
+	Alias *AliasAliasesAlias `json:"alias,omitempty"`
+}
+
+// AliasSetRequest is the body holding alias
+type AliasSetRequest struct {
+	Alias *Alias `json:"alias,omitempty"`
+}
+
+// Filter is the model OPNsense/Firewall/Filter
+type Filter struct {
+	Rules *FilterRules `json:"rules,omitempty"`
+}
+
+// FilterAddRuleRequest is the body holding rule
+type FilterAddRuleRequest struct {
+	Rule *FilterRulesRule `json:"rule,omitempty"`
+}
+
+// FilterGetResponse is the body holding filter
+type FilterGetResponse struct {
+	Filter *Filter `json:"filter,omitempty"`
+}
+
+// FilterGetRuleResponse is the body holding rule
+type FilterGetRuleResponse struct {
+	Rule *FilterRulesRule `json:"rule,omitempty"`
+}
+
+// FilterRules is the rules container
+type FilterRules struct {
+	Rule map[string]FilterRulesRule `json:"rule,omitempty"` // ArrayField
+}
+
+// FilterRulesRule is the rules.rule node of model OPNsense/Firewall/Filter
+type FilterRulesRule struct {
+	Enabled        api.Field `json:"enabled,omitempty"`         // BooleanField, required, default 1
+	Sequence       api.Field `json:"sequence,omitempty"`        // IntegerField, required, default 1
+	Action         api.Field `json:"action,omitempty"`          // OptionField, required, default pass, options pass|block|reject
+	Interface      api.Field `json:"interface,omitempty"`       // InterfaceField
+	SourceNet      api.Field `json:"source_net,omitempty"`      // NetworkField, default any
+	DestinationNet api.Field `json:"destination_net,omitempty"` // NetworkField, default any
+	Description    api.Field `json:"description,omitempty"`     // DescriptionField
+}
+
+// FilterSetRequest is the body holding filter
+type FilterSetRequest struct {
+	Filter *Filter `json:"filter,omitempty"`
+}
+
+// FilterSetRuleRequest is the body holding rule
+type FilterSetRuleRequest struct {
+	Rule *FilterRulesRule `json:"rule,omitempty"`
+}
+
+// AliasController calls the firewall Alias API commands
+type AliasController struct {
+	client *api.Client
+}
+
+// AddItem calls POST /api/firewall/alias/addItem
+func (c *AliasController) AddItem(ctx context.Context, body *AliasAddItemRequest) (*api.Response, error) {
+	method := "POST"
+	var data interface{}
+	if body != nil {
+		data = body
+	}
+	return c.client.Call(ctx, method, "firewall/alias/addItem", nil, data)
+}
+
+// AliasDelItemParams are the path parameters of DelItem
+type AliasDelItemParams struct {
+	Uuid string
+}
+
+// DelItem calls POST /api/firewall/alias/delItem
+func (c *AliasController) DelItem(ctx context.Context, params AliasDelItemParams) (*api.Response, error) {
+	if len(params.Uuid) == 0 {
+		return nil, fmt.Errorf("firewall/alias/delItem: parameter uuid is required")
+	}
+	method := "POST"
+	var data interface{}
+	return c.client.Call(ctx, method, "firewall/alias/delItem", []string{params.Uuid}, data)
+}
+
+// Get calls GET /api/firewall/alias/get
+func (c *AliasController) Get(ctx context.Context) (*AliasGetResponse, error) {
+	method := "GET"
+	var data interface{}
+	resp, err := c.client.Call(ctx, method, "firewall/alias/get", nil, data)
+	if err != nil {
+		return nil, err
+	}
+	result := &AliasGetResponse{}
+	return result, resp.Decode(result)
+}
+
+// SearchItem calls GET /api/firewall/alias/searchItem. The search options are posted when given
+func (c *AliasController) SearchItem(ctx context.Context, opts *api.SearchOptions) (*api.SearchResult, error) {
+	method := "GET"
+	var data interface{}
+	if opts != nil {
+		method, data = "POST", opts
+	}
+	resp, err := c.client.Call(ctx, method, "firewall/alias/searchItem", nil, data)
+	if err != nil {
+		return nil, err
+	}
+	result := &api.SearchResult{}
+	return result, resp.Decode(result)
+}
+
+// Set calls POST /api/firewall/alias/set
+func (c *AliasController) Set(ctx context.Context, body *AliasSetRequest) (*api.Response, error) {
+	method := "POST"
+	var data interface{}
+	if body != nil {
+		data = body
+	}
+	return c.client.Call(ctx, method, "firewall/alias/set", nil, data)
+}
+
+// AliasSetItemParams are the path parameters of SetItem
+type AliasSetItemParams struct {
+	Uuid string
+}
+
+// SetItem calls POST /api/firewall/alias/setItem
+func (c *AliasController) SetItem(ctx context.Context, params AliasSetItemParams, body *AliasSetItemRequest) (*api.Response, error) {
+	if len(params.Uuid) == 0 {
+		return nil, fmt.Errorf("firewall/alias/setItem: parameter uuid is required")
+	}
+	method := "POST"
+	var data interface{}
+	if body != nil {
+		data = body
+	}
+	return c.client.Call(ctx, method, "firewall/alias/setItem", []string{params.Uuid}, data)
+}
+
+// FilterController calls the firewall Filter API commands
+type FilterController struct {
+	client *api.Client
+}
+
+// AddRule calls POST /api/firewall/filter/addRule
+func (c *FilterController) AddRule(ctx context.Context, body *FilterAddRuleRequest) (*api.Response, error) {
+	method := "POST"
+	var data interface{}
+	if body != nil {
+		data = body
+	}
+	return c.client.Call(ctx, method, "firewall/filter/addRule", nil, data)
+}
+
+// FilterApplyParams are the path parameters of Apply
+type FilterApplyParams struct {
+	RollbackRevision string // optional
+}
+
+// Apply calls POST /api/firewall/filter/apply
+func (c *FilterController) Apply(ctx context.Context, params FilterApplyParams) (*api.Response, error) {
+	method := "POST"
+	var data interface{}
+	return c.client.Call(ctx, method, "firewall/filter/apply", []string{params.RollbackRevision}, data)
+}
+
+// FilterCancelRollbackParams are the path parameters of CancelRollback
+type FilterCancelRollbackParams struct {
+	RollbackRevision string
+}
+
+// CancelRollback calls POST /api/firewall/filter/cancelRollback
+func (c *FilterController) CancelRollback(ctx context.Context, params FilterCancelRollbackParams) (*api.Response, error) {
+	if len(params.RollbackRevision) == 0 {
+		return nil, fmt.Errorf("firewall/filter/cancelRollback: parameter rollback_revision is required")
+	}
+	method := "POST"
+	var data interface{}
+	return c.client.Call(ctx, method, "firewall/filter/cancelRollback", []string{params.RollbackRevision}, data)
+}
+
+// FilterDelRuleParams are the path parameters of DelRule
+type FilterDelRuleParams struct {
+	Uuid string
+}
+
+// DelRule calls POST /api/firewall/filter/delRule
+func (c *FilterController) DelRule(ctx context.Context, params FilterDelRuleParams) (*api.Response, error) {
+	if len(params.Uuid) == 0 {
+		return nil, fmt.Errorf("firewall/filter/delRule: parameter uuid is required")
+	}
+	method := "POST"
+	var data interface{}
+	return c.client.Call(ctx, method, "firewall/filter/delRule", []string{params.Uuid}, data)
+}
+
+// Get calls GET /api/firewall/filter/get
+func (c *FilterController) Get(ctx context.Context) (*FilterGetResponse, error) {
+	method := "GET"
+	var data interface{}
+	resp, err := c.client.Call(ctx, method, "firewall/filter/get", nil, data)
+	if err != nil {
+		return nil, err
+	}
+	result := &FilterGetResponse{}
+	return result, resp.Decode(result)
+}
+
+// FilterGetRuleParams are the path parameters of GetRule
+type FilterGetRuleParams struct {
+	Uuid string // optional
+}
+
+// GetRule calls GET /api/firewall/filter/getRule
+func (c *FilterController) GetRule(ctx context.Context, params FilterGetRuleParams) (*FilterGetRuleResponse, error) {
+	method := "GET"
+	var data interface{}
+	resp, err := c.client.Call(ctx, method, "firewall/filter/getRule", []string{params.Uuid}, data)
+	if err != nil {
+		return nil, err
+	}
+	result := &FilterGetRuleResponse{}
+	return result, resp.Decode(result)
+}
+
+// FilterRevertParams are the path parameters of Revert
+type FilterRevertParams struct {
+	Revision string
+}
+
+// Revert calls POST /api/firewall/filter/revert
+func (c *FilterController) Revert(ctx context.Context, params FilterRevertParams) (*api.Response, error) {
+	if len(params.Revision) == 0 {
+		return nil, fmt.Errorf("firewall/filter/revert: parameter revision is required")
+	}
+	method := "POST"
+	var data interface{}
+	return c.client.Call(ctx, method, "firewall/filter/revert", []string{params.Revision}, data)
+}
+
+// Savepoint calls POST /api/firewall/filter/savepoint
+func (c *FilterController) Savepoint(ctx context.Context) (*api.Response, error) {
+	method := "POST"
+	var data interface{}
+	return c.client.Call(ctx, method, "firewall/filter/savepoint", nil, data)
+}
+
+// SearchRule calls GET /api/firewall/filter/searchRule. The search options are posted when given
+func (c *FilterController) SearchRule(ctx context.Context, opts *api.SearchOptions) (*api.SearchResult, error) {
+	method := "GET"
+	var data interface{}
+	if opts != nil {
+		method, data = "POST", opts
+	}
+	resp, err := c.client.Call(ctx, method, "firewall/filter/searchRule", nil, data)
+	if err != nil {
+		return nil, err
+	}
+	result := &api.SearchResult{}
+	return result, resp.Decode(result)
+}
+
+// Set calls POST /api/firewall/filter/set
+func (c *FilterController) Set(ctx context.Context, body *FilterSetRequest) (*api.Response, error) {
+	method := "POST"
+	var data interface{}
+	if body != nil {
+		data = body
+	}
+	return c.client.Call(ctx, method, "firewall/filter/set", nil, data)
+}
+
+// FilterSetRuleParams are the path parameters of SetRule
+type FilterSetRuleParams struct {
+	Uuid string
+}
+
+// SetRule calls POST /api/firewall/filter/setRule
+func (c *FilterController) SetRule(ctx context.Context, params FilterSetRuleParams, body *FilterSetRuleRequest) (*api.Response, error) {
+	if len(params.Uuid) == 0 {
+		return nil, fmt.Errorf("firewall/filter/setRule: parameter uuid is required")
+	}
+	method := "POST"
+	var data interface{}
+	if body != nil {
+		data = body
+	}
+	return c.client.Call(ctx, method, "firewall/filter/setRule", []string{params.Uuid}, data)
+}
+
+// FilterToggleRuleParams are the path parameters of ToggleRule
+type FilterToggleRuleParams struct {
+	Uuid    string
+	Enabled string // optional
+}
+
+// ToggleRule calls POST /api/firewall/filter/toggleRule
+func (c *FilterController) ToggleRule(ctx context.Context, params FilterToggleRuleParams) (*api.Response, error) {
+	if len(params.Uuid) == 0 {
+		return nil, fmt.Errorf("firewall/filter/toggleRule: parameter uuid is required")
+	}
+	method := "POST"
+	var data interface{}
+	return c.client.Call(ctx, method, "firewall/filter/toggleRule", []string{params.Uuid, params.Enabled}, data)
+}
@@ -0,0 +1,173 @@
+// Code generated by opnsense-cli generator. DO NOT EDIT.
+
+// Package wol calls the OPNsense wol API
+package wol
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/thedataflows/opnsense-cli/pkg/api"
+)
+
+// Client holds the wol controllers
+type Client struct {
+	Service *ServiceController
+	Wol     *WolController
+}
+
+// New returns the wol client calling the API with client
+func New(client *api.Client) *Client {
+	return &Client{
+		Service: &ServiceController{client: client},
+		Wol:     &WolController{client: client},
+	}
+}
+
+// Wol is the model OPNsense/Wol/Wol
+type Wol struct {
+	Wolentry map[string]WolWolentry `json:"wolentry,omitempty"` // ArrayField
+}
+
+// WolAddHostRequest is the body holding host
+type WolAddHostRequest struct {
+	Host *WolWolentry `json:"host,omitempty"`
+}
+
+// WolGetHostResponse is the body holding host
+type WolGetHostResponse struct {
+	Host *WolWolentry `json:"host,omitempty"`
+}
+
+// WolGetResponse is the body holding wol
+type WolGetResponse struct {
+	Wol *Wol `json:"wol,omitempty"`
+}
+
+// WolWolentry is the wolentry node of model OPNsense/Wol/Wol
+type WolWolentry struct {
+	Interface api.Field `json:"interface,omitempty"` // InterfaceField, required
+	Mac       api.Field `json:"mac,omitempty"`       // TextField, required
+	Descr     api.Field `json:"descr,omitempty"`     // TextField
+}
+
+// ServiceController calls the wol Service API commands
+type ServiceController struct {
+	client *api.Client
+}
+
+// Reconfigure calls POST /api/wol/service/reconfigure
+func (c *ServiceController) Reconfigure(ctx context.Context) (*api.Response, error) {
+	method := "POST"
+	var data interface{}
+	return c.client.Call(ctx, method, "wol/service/reconfigure", nil, data)
+}
+
+// Restart calls POST /api/wol/service/restart
+func (c *ServiceController) Restart(ctx context.Context) (*api.Response, error) {
+	method := "POST"
+	var data interface{}
+	return c.client.Call(ctx, method, "wol/service/restart", nil, data)
+}
+
+// Start calls POST /api/wol/service/start
+func (c *ServiceController) Start(ctx context.Context) (*api.Response, error) {
+	method := "POST"
+	var data interface{}
+	return c.client.Call(ctx, method, "wol/service/start", nil, data)
+}
+
+// Status calls GET /api/wol/service/status
+func (c *ServiceController) Status(ctx context.Context) (*api.Response, error) {
+	method := "GET"
+	var data interface{}
+	return c.client.Call(ctx, method, "wol/service/status", nil, data)
+}
+
+// Stop calls POST /api/wol/service/stop
+func (c *ServiceController) Stop(ctx context.Context) (*api.Response, error) {
+	method := "POST"
+	var data interface{}
+	return c.client.Call(ctx, method, "wol/service/stop", nil, data)
+}
+
+// WolController calls the wol Wol API commands
+type WolController struct {
+	client *api.Client
+}
+
+// AddHost calls POST /api/wol/wol/addHost
+func (c *WolController) AddHost(ctx context.Context, body *WolAddHostRequest) (*api.Response, error) {
+	method := "POST"
+	var data interface{}
+	if body != nil {
+		data = body
+	}
+	return c.client.Call(ctx, method, "wol/wol/addHost", nil, data)
+}
+
+// WolDelHostParams are the path parameters of DelHost
+type WolDelHostParams struct {
+	Uuid string
+}
+
+// DelHost calls POST /api/wol/wol/delHost
+func (c *WolController) DelHost(ctx context.Context, params WolDelHostParams) (*api.Response, error) {
+	if len(params.Uuid) == 0 {
+		return nil, fmt.Errorf("wol/wol/delHost: parameter uuid is required")
+	}
+	method := "POST"
+	var data interface{}
+	return c.client.Call(ctx, method, "wol/wol/delHost", []string{params.Uuid}, data)
+}
+
+// Get calls GET /api/wol/wol/get
+func (c *WolController) Get(ctx context.Context) (*WolGetResponse, error) {
+	method := "GET"
+	var data interface{}
+	resp, err := c.client.Call(ctx, method, "wol/wol/get", nil, data)
+	if err != nil {
+		return nil, err
+	}
+	result := &WolGetResponse{}
+	return result, resp.Decode(result)
+}
+
+// WolGetHostParams are the path parameters of GetHost
+type WolGetHostParams struct {
+	Uuid string // optional
+}
+
+// GetHost calls GET /api/wol/wol/getHost
+func (c *WolController) GetHost(ctx context.Context, params WolGetHostParams) (*WolGetHostResponse, error) {
+	method := "GET"
+	var data interface{}
+	resp, err := c.client.Call(ctx, method, "wol/wol/getHost", []string{params.Uuid}, data)
+	if err != nil {
+		return nil, err
+	}
+	result := &WolGetHostResponse{}
+	return result, resp.Decode(result)
+}
+
+// SearchHost calls GET /api/wol/wol/searchHost. The search options are posted when given
+func (c *WolController) SearchHost(ctx context.Context, opts *api.SearchOptions) (*api.SearchResult, error) {
+	method := "GET"
+	var data interface{}
+	if opts != nil {
+		method, data = "POST", opts
+	}
+	resp, err := c.client.Call(ctx, method, "wol/wol/searchHost", nil, data)
+	if err != nil {
+		return nil, err
+	}
+	result := &api.SearchResult{}
+	return result, resp.Decode(result)
+}
+
+// Set calls POST /api/wol/wol/set
+func (c *WolController) Set(ctx context.Context) (*api.Response, error) {
+	method := "POST"
+	var data interface{}
+	return c.client.Call(ctx, method, "wol/wol/set", nil, data)
+}
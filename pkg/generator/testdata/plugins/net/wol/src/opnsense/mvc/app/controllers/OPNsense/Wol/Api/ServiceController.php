@@ -0,0 +1,8 @@
+<?php
+namespace OPNsense\Wol\Api;
+
+class ServiceController extends \OPNsense\Base\ApiMutableServiceControllerBase
+{
+    protected static $internalServiceClass = '\OPNsense\Wol\Wol';
+    protected static $internalServiceName = 'wol';
+}
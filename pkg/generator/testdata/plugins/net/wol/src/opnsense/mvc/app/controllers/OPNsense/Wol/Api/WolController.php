@@ -0,0 +1,40 @@
+<?php
+namespace OPNsense\Wol\Api;
+
+use OPNsense\Base\ApiMutableModelControllerBase;
+
+class WolController extends ApiMutableModelControllerBase
+{
+    protected static $internalModelName = 'wol';
+    protected static $internalModelClass = '\OPNsense\Wol\Wol';
+
+    public function setAction()
+    {
+        $result = array("status" => "failed");
+        if ($this->request->isPost() && $this->request->hasPost('wake')) {
+            $mac = $this->request->getPost('wake')['mac'];
+            $result["status"] = "ok";
+        }
+        return $result;
+    }
+
+    public function searchHostAction()
+    {
+        return $this->searchBase("wolentry", array('interface', 'mac', 'descr'), "descr");
+    }
+
+    public function addHostAction()
+    {
+        return $this->addBase('host', 'wolentry');
+    }
+
+    public function getHostAction($uuid = null)
+    {
+        return $this->getBase('host', 'wolentry', $uuid);
+    }
+
+    public function delHostAction($uuid)
+    {
+        return $this->delBase('wolentry', $uuid);
+    }
+}
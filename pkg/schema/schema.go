@@ -0,0 +1,150 @@
+package schema
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/pflag"
+)
+
+// Draft is the JSON Schema dialect of the generated schemas
+const Draft = "https://json-schema.org/draft/2020-12/schema"
+
+// Schema is a JSON Schema document or subschema
+type Schema struct {
+	Schema               string             `json:"$schema,omitempty"`
+	Ref                  string             `json:"$ref,omitempty"`
+	Title                string             `json:"title,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties interface{}        `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	OneOf                []*Schema          `json:"oneOf,omitempty"`
+	Default              interface{}        `json:"default,omitempty"`
+	Defs                 map[string]*Schema `json:"$defs,omitempty"`
+}
+
+// Reflect returns the schema of the YAML documents decoded strictly into values of type t. Struct fields are named
+// after their yaml tag, required unless omitempty or pointers, and unknown fields are not allowed. Structs are
+// defined once in Defs, so they may be recursive. descriptions are keyed by type name, e.g. 'Macro', or by type
+// and field name, e.g. 'Macro.schedule'
+func Reflect(t reflect.Type, title string, descriptions map[string]string) *Schema {
+	r := &reflector{defs: map[string]*Schema{}, descriptions: descriptions}
+	s := r.schema(t)
+	s.Schema = Draft
+	s.Title = title
+	s.Defs = r.defs
+	return s
+}
+
+type reflector struct {
+	defs         map[string]*Schema
+	descriptions map[string]string
+}
+
+func (r *reflector) schema(t reflect.Type) *Schema {
+	switch t.Kind() {
+	case reflect.Ptr:
+		return r.schema(t.Elem())
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: r.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: r.schema(t.Elem())}
+	case reflect.Struct:
+		if _, ok := r.defs[t.Name()]; !ok {
+			// define it before the fields, which may refer to it
+			def := &Schema{}
+			r.defs[t.Name()] = def
+			*def = *r.object(t)
+		}
+		return &Schema{Ref: "#/$defs/" + t.Name()}
+	}
+	return &Schema{}
+}
+
+// object returns the schema of the struct type t
+func (r *reflector) object(t reflect.Type) *Schema {
+	s := &Schema{
+		Type:                 "object",
+		Description:          r.descriptions[t.Name()],
+		Properties:           map[string]*Schema{},
+		AdditionalProperties: false,
+	}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, omitEmpty := yamlName(f)
+		if name == "-" {
+			continue
+		}
+		property := r.schema(f.Type)
+		// siblings of $ref, like description, are allowed since draft 2019-09
+		property.Description = r.descriptions[t.Name()+"."+name]
+		s.Properties[name] = property
+		if !omitEmpty && f.Type.Kind() != reflect.Ptr {
+			s.Required = append(s.Required, name)
+		}
+	}
+	return s
+}
+
+// yamlName returns the name of the field in YAML, lower cased by default like go-yaml, and whether it is omitempty
+func yamlName(f reflect.StructField) (string, bool) {
+	parts := strings.Split(f.Tag.Get("yaml"), ",")
+	name := parts[0]
+	if len(name) == 0 {
+		name = strings.ToLower(f.Name)
+	}
+	for _, option := range parts[1:] {
+		if option == "omitempty" {
+			return name, true
+		}
+	}
+	return name, false
+}
+
+// FromFlag returns the schema of the config file key set by the command line flag f, with its usage and default
+func FromFlag(f *pflag.Flag) *Schema {
+	s := &Schema{Description: f.Usage}
+	switch t := f.Value.Type(); {
+	case t == "bool":
+		s.Type = "boolean"
+		s.Default, _ = strconv.ParseBool(f.DefValue)
+	case strings.HasPrefix(t, "int") || strings.HasPrefix(t, "uint"):
+		s.Type = "integer"
+		s.Default, _ = strconv.ParseInt(f.DefValue, 10, 64)
+	case strings.HasPrefix(t, "float"):
+		s.Type = "number"
+		s.Default, _ = strconv.ParseFloat(f.DefValue, 64)
+	case t == "stringToString":
+		// set as name=value on the command line
+		s.Type = "object"
+		s.AdditionalProperties = &Schema{Type: "string"}
+	case strings.HasSuffix(t, "Slice") || strings.HasSuffix(t, "Array"):
+		s.Type = "array"
+		s.Items = &Schema{Type: "string"}
+		if values := strings.Trim(f.DefValue, "[]"); len(values) > 0 {
+			s.Default = strings.Split(values, ",")
+		}
+	default:
+		s.Type = "string"
+		if len(f.DefValue) > 0 {
+			s.Default = f.DefValue
+		}
+	}
+	return s
+}
@@ -0,0 +1,83 @@
+package schema
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/spf13/pflag"
+)
+
+type testItem struct {
+	Name     string            `yaml:"name"`
+	Count    int               `yaml:"count,omitempty"`
+	Enabled  *bool             `yaml:"enabled"`
+	Children []testItem        `yaml:"children,omitempty"`
+	Labels   map[string]string `yaml:",omitempty"`
+	Ignored  string            `yaml:"-"`
+	internal string
+}
+
+func TestReflect(t *testing.T) {
+	got := Reflect(reflect.TypeOf([]testItem{}), "items", map[string]string{
+		"testItem":      "An item",
+		"testItem.name": "Name of the item",
+	})
+	want := &Schema{
+		Schema: Draft,
+		Title:  "items",
+		Type:   "array",
+		Items:  &Schema{Ref: "#/$defs/testItem"},
+		Defs: map[string]*Schema{
+			"testItem": {
+				Type:        "object",
+				Description: "An item",
+				Properties: map[string]*Schema{
+					"name":    {Type: "string", Description: "Name of the item"},
+					"count":   {Type: "integer"},
+					"enabled": {Type: "boolean"},
+					// recursive types refer to their definition
+					"children": {Type: "array", Items: &Schema{Ref: "#/$defs/testItem"}},
+					"labels":   {Type: "object", AdditionalProperties: &Schema{Type: "string"}},
+				},
+				// omitempty fields and pointers are optional
+				Required:             []string{"name"},
+				AdditionalProperties: false,
+			},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Reflect() = %+v, want %+v", got, want)
+	}
+}
+
+func TestFromFlag(t *testing.T) {
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	flags.Bool("yes", false, "Assume yes")
+	flags.Int("retries", 3, "Retries")
+	flags.Float64("ratio", 0.5, "Ratio")
+	flags.String("url", "https://localhost", "URL")
+	flags.String("empty", "", "Empty")
+	flags.StringSlice("files", []string{"a.yaml", "b.yaml"}, "Files")
+	flags.StringArray("field", nil, "Fields")
+	flags.StringToString("var", nil, "Variables")
+	tests := []struct {
+		flag string
+		want *Schema
+	}{
+		{flag: "yes", want: &Schema{Description: "Assume yes", Type: "boolean", Default: false}},
+		{flag: "retries", want: &Schema{Description: "Retries", Type: "integer", Default: int64(3)}},
+		{flag: "ratio", want: &Schema{Description: "Ratio", Type: "number", Default: 0.5}},
+		{flag: "url", want: &Schema{Description: "URL", Type: "string", Default: "https://localhost"}},
+		{flag: "empty", want: &Schema{Description: "Empty", Type: "string"}},
+		{flag: "files", want: &Schema{Description: "Files", Type: "array", Items: &Schema{Type: "string"}, Default: []string{"a.yaml", "b.yaml"}}},
+		{flag: "field", want: &Schema{Description: "Fields", Type: "array", Items: &Schema{Type: "string"}}},
+		{flag: "var", want: &Schema{Description: "Variables", Type: "object", AdditionalProperties: &Schema{Type: "string"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.flag, func(t *testing.T) {
+			if got := FromFlag(flags.Lookup(tt.flag)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FromFlag() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}